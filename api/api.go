@@ -41,6 +41,9 @@ func (api *API) Start(port string) error {
 	//
 	api.Worker.QueueAndProcess(&jobs.Startup{})
 
+	//
+	api.startWatcher()
+
 	//
 	routes, err := api.registerRoutes()
 	if err != nil {
@@ -70,20 +73,38 @@ func (api *API) registerRoutes() (*pat.Router, error) {
 		rw.Write([]byte("pong"))
 	})
 
-	router.Get("/logs", config.LogHandler)
+	router.Get("/logs", api.handleRequest(api.ListLogs))
+	router.Delete("/logs", api.handleRequest(api.DeleteLogs))
+	router.Get("/jobs/{id}/logs", api.handleRequest(api.ListJobLogs))
+	router.Get("/drains", api.handleRequest(api.ListDrains))
+	router.Post("/drains", api.handleRequest(api.CreateDrain))
+	router.Delete("/drains/{name}", api.handleRequest(api.DeleteDrain))
+	router.Get("/events", api.handleRequest(api.StreamEvents))
+	router.Get("/webhooks", api.handleRequest(api.ListWebhooks))
+	router.Post("/webhooks", api.handleRequest(api.CreateWebhook))
+	router.Delete("/webhooks/{id}", api.handleRequest(api.DeleteWebhook))
+	router.Get("/ca.pem", api.handleRequest(api.ShowCA))
 
 	router.Put("/suspend", api.handleRequest(api.Suspend))
 	router.Put("/lock", api.handleRequest(api.Lock))
 	router.Get("/lock-count", api.handleRequest(api.LockCount))
 
 	router.Post("/develop", api.handleRequest(api.Develop))
+	router.Post("/dev", api.handleRequest(api.CreateDev))
+	router.Get("/dev", api.handleRequest(api.ListDevs))
+	router.Delete("/dev", api.handleRequest(api.DeleteDev))
 	router.Post("/exec", api.handleRequest(api.Exec))
 
+	router.Get("/console/sessions", api.handleRequest(api.ListSessions))
+	router.Post("/console/attach", api.handleRequest(api.AttachSession))
 	router.Post("/console", api.handleRequest(api.Exec))
 	router.Post("/resizeexec", api.handleRequest(api.ResizeExec))
 
 	router.Get("/libdirs", api.handleRequest(api.LibDirs))
 	router.Post("/file-change", api.handleRequest(api.FileChange))
+	router.Post("/watch", api.handleRequest(api.StartWatch))
+	router.Get("/watch", api.handleRequest(api.ShowWatch))
+	router.Delete("/watch", api.handleRequest(api.StopWatch))
 
 	router.Post("/bootstrap", api.handleRequest(api.CreateBootstrap))
 	router.Post("/builds", api.handleRequest(api.CreateBuild))
@@ -91,8 +112,12 @@ func (api *API) registerRoutes() (*pat.Router, error) {
 	router.Post("/image-update", api.handleRequest(api.UpdateImages))
 
 	router.Get("/services", api.handleRequest(api.ListServices))
+	router.Get("/routes/health", api.handleRequest(api.ListRoutesHealth))
 	router.Get("/routes", api.handleRequest(api.ListRoutes))
+	router.Post("/routes", api.handleRequest(api.CreateRoute))
+	router.Delete("/routes/{id}", api.handleRequest(api.DeleteRoute))
 	router.Get("/vips", api.handleRequest(api.ListVips))
+	router.Get("/ports", api.handleRequest(api.ListPorts))
 	return router, nil
 }
 
@@ -0,0 +1,25 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// ShowCA hands out the development certificate authority so it can be
+// trusted by the developer's browser
+func (api *API) ShowCA(rw http.ResponseWriter, req *http.Request) {
+	if config.CertAuthority == nil {
+		writeBody(map[string]string{"error": "tls is not available"}, rw, http.StatusNotFound)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-pem-file")
+	rw.Write(config.CertAuthority.PEM())
+}
@@ -7,8 +7,11 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"regexp"
 	"sync"
+	"time"
 
 	"github.com/nanobox-io/nanobox-server/config"
 	"github.com/nanobox-io/nanobox-server/jobs"
@@ -19,89 +22,421 @@ import (
 
 var developTex = sync.Mutex{}
 
+// devNames are the names a dev environment can have, they end up in the
+// container uid and the Boxfile node
+var devNames = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// devEnv keeps track of who is using a dev container. Every console and
+// every POST /dev holds a lease and the container is only removed once the
+// last lease is released and the idle timeout has passed (unless it has been
+// asked to keep running).
+type devEnv struct {
+	Name        string
+	UID         string
+	Running     bool
+	Creating    bool
+	Refs        int
+	Keep        bool
+	IdleTimeout time.Duration
+	LastUsed    time.Time
+	DevConfig   string
+
+	leases   map[string]bool
+	creating chan struct{}
+	timer    *time.Timer
+}
+
+var devs = map[string]*devEnv{}
+
+// removing are the dev containers being removed by uid, creating a container
+// with the same uid waits for them
+var removing = map[string]chan struct{}{}
+
+// getDev returns the dev environment with the given name and adds it when it
+// is new. The unnamed environment lives in the dev1 container and the others
+// in dev-<name>.
+func getDev(name string) (*devEnv, error) {
+	if name != "" && !devNames.MatchString(name) {
+		return nil, fmt.Errorf("%q is not a valid dev name, use letters, numbers, '-' and '_'", name)
+	}
+
+	developTex.Lock()
+	defer developTex.Unlock()
+
+	if dev, ok := devs[name]; ok {
+		return dev, nil
+	}
+
+	uid := "dev1"
+	if name != "" {
+		uid = "dev-" + name
+	}
+	devs[name] = &devEnv{Name: name, UID: uid, leases: map[string]bool{}}
+	return devs[name], nil
+}
+
+// findDev returns the dev environment with the given name, or nil if nobody
+// has used it
+func findDev(name string) *devEnv {
+	developTex.Lock()
+	defer developTex.Unlock()
+	return devs[name]
+}
+
 func (api *API) Develop(rw http.ResponseWriter, req *http.Request) {
 	err := req.ParseMultipartForm(32 << 20)
 	if err != nil {
 		config.Log.Debug("form parsing error: \n %s", err.Error())
 	}
 
-	// force the exec route to go into a dev1 container
-	req.Form["container"] = []string{"dev1"}
-
-	box := jobs.CombinedBoxfile(false)
-
-	image := "nanobox/build"
-	if stab := box.Node("build").StringValue("stability"); stab != "" {
-		image = image + ":" + stab
+	dev, err := getDev(req.FormValue("name"))
+	if err != nil {
+		rw.Write([]byte(err.Error()))
+		return
 	}
 
-	control, err := ensureContainer(image, req.FormValue("dev_config"))
+	// force the exec route to go into the dev container
+	req.Form["container"] = []string{dev.UID}
+
+	lease, err := dev.acquire(req.FormValue("dev_config"))
 	if err != nil {
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	defer dev.release(lease)
 
 	api.Exec(rw, req)
+}
 
-	if control {
-		execWait.Wait()
-		docker.RemoveContainer("dev1")
+// CreateDev makes sure the dev container is running and holds a lease on it
+// until a DELETE /dev with the lease id
+func (api *API) CreateDev(rw http.ResponseWriter, req *http.Request) {
+	dev, err := getDev(req.FormValue("name"))
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
 	}
+
+	if err := dev.configure(req.FormValue("keep"), req.FormValue("idle_timeout")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	lease, err := dev.acquire(req.FormValue("dev_config"))
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(struct {
+		devEnv
+		Lease string
+	}{dev.status(), lease}, rw, http.StatusCreated)
+}
+
+// ListDevs shows every dev environment or just the one asked for by name
+func (api *API) ListDevs(rw http.ResponseWriter, req *http.Request) {
+	if name := req.FormValue("name"); name != "" {
+		dev := findDev(name)
+		if dev == nil {
+			writeBody(map[string]string{"error": "no such dev environment"}, rw, http.StatusNotFound)
+			return
+		}
+		writeBody(dev.status(), rw, http.StatusOK)
+		return
+	}
+
+	developTex.Lock()
+	list := []*devEnv{}
+	for _, dev := range devs {
+		list = append(list, dev)
+	}
+	developTex.Unlock()
+
+	statuses := []devEnv{}
+	for _, dev := range list {
+		statuses = append(statuses, dev.status())
+	}
+	writeBody(statuses, rw, http.StatusOK)
+}
+
+// DeleteDev releases the lease taken by POST /dev. When force is given the
+// container is removed right away no matter who is still using it.
+func (api *API) DeleteDev(rw http.ResponseWriter, req *http.Request) {
+	dev := findDev(req.FormValue("name"))
+	if dev == nil {
+		writeBody(map[string]string{"error": "no such dev environment"}, rw, http.StatusNotFound)
+		return
+	}
+
+	if req.FormValue("force") == "true" {
+		dev.destroy()
+	} else if err := dev.release(req.FormValue("lease")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusNotFound)
+		return
+	}
+
+	writeBody(dev.status(), rw, http.StatusOK)
 }
 
-func ensureContainer(image, dev_config string) (control bool, err error) {
+// configure updates the keep and idle timeout settings when they are given
+func (d *devEnv) configure(keep, idleTimeout string) error {
 	developTex.Lock()
 	defer developTex.Unlock()
-	// if there is no dev1 it needs to be created and this thread needs to remember
-	// to shut it down when its done conatinerControl is used for that purpose
-	container, err := docker.GetContainer("dev1")
-	if err != nil || !container.State.Running {
-		config.Log.Debug("develop controlling container")
-		config.Log.Debug("develop container: %+v", container)
-		if container != nil {
-			config.Log.Debug("develop container config: %+v", container.Config)
-			config.Log.Debug("develop container host: %+v", container.HostConfig)
+
+	if keep != "" {
+		d.Keep = (keep == "true")
+	}
+
+	if idleTimeout != "" {
+		timeout, err := time.ParseDuration(idleTimeout)
+		if err != nil {
+			return err
 		}
+		d.IdleTimeout = timeout
+	}
+	return nil
+}
+
+// acquire creates the dev container if it isnt running and returns a new
+// lease on it. The container is created without holding the developTex lock
+// so the other environments aren't held up, requests for the same
+// environment wait for it to finish.
+func (d *devEnv) acquire(devConfig string) (string, error) {
+	developTex.Lock()
+
+	// somebody is back before the idle timeout ran out
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
 
-		if container != nil && !container.State.Running {
-			config.Log.Debug("removing old dev1")
-			err = docker.RemoveContainer(container.ID)
-			if err != nil {
-				config.Log.Debug("develop remove containter: %s", err.Error())
-				return false, err
-			}
+	// each environment remembers the last dev_config it was given
+	if devConfig != "" {
+		d.DevConfig = devConfig
+	}
+
+	for d.creating != nil || removing[d.UID] != nil {
+		wait := d.creating
+		if wait == nil {
+			wait = removing[d.UID]
 		}
-		control = true
-		err = nil
+		developTex.Unlock()
+		<-wait
+		developTex.Lock()
+	}
+
+	// the environment could have been removed while we waited
+	if devs[d.Name] == nil {
+		devs[d.Name] = d
+	}
 
-		// give docker a new set of lib dirs
-		jobs.SetLibDirs()
-		jobs.SetWorkingDir()
+	d.creating = make(chan struct{})
+	d.Creating = true
+	devConfig = d.DevConfig
+	developTex.Unlock()
 
-		container, err = docker.CreateContainer(docker.CreateConfig{Image: image, Category: "dev", UID: "dev1"})
-		if err != nil {
-			config.Log.Debug("develop create containter: %s", err.Error())
-			return false, err
+	created, err := ensureContainer(d.Name, d.UID, devConfig)
+
+	developTex.Lock()
+	defer developTex.Unlock()
+
+	close(d.creating)
+	d.creating = nil
+	d.Creating = false
+
+	if err != nil {
+		return "", err
+	}
+
+	// pick up the boxfile defaults when we make a fresh container
+	if created && len(d.leases) == 0 {
+		box := jobs.DevBoxfile(d.Name)
+		if box.BoolValue("keep_running") {
+			d.Keep = true
+		}
+		if timeout, err := time.ParseDuration(box.StringValue("idle_timeout")); err == nil {
+			d.IdleTimeout = timeout
 		}
+	}
 
-		// run the default-user hook to get ssh keys setup
-		out, err := script.Exec("default-user", "dev1", fs.UserPayload())
-		if err != nil {
-			config.Log.Debug("Failed script output: \n %s", out)
-			config.Log.Debug("out: %s", string(out))
+	lease := newUUID()
+	d.leases[lease] = true
+	d.Refs = len(d.leases)
+	d.Running = true
+	d.LastUsed = time.Now()
+	return lease, nil
+}
+
+// release drops a lease and schedules the container to be removed when
+// nobody is left using it
+func (d *devEnv) release(lease string) error {
+	developTex.Lock()
+
+	if !d.leases[lease] {
+		developTex.Unlock()
+		return fmt.Errorf("no such lease")
+	}
+	delete(d.leases, lease)
+	d.Refs = len(d.leases)
+	d.LastUsed = time.Now()
+
+	if d.Refs > 0 || d.Keep || !d.Running {
+		developTex.Unlock()
+		return nil
+	}
+
+	if d.IdleTimeout <= 0 {
+		d.forget()
+		developTex.Unlock()
+		removeContainer(d.UID)
+		return nil
+	}
+
+	d.timer = time.AfterFunc(d.IdleTimeout, func() {
+		developTex.Lock()
+		d.timer = nil
+		idle := d.Refs == 0 && !d.Keep && d.creating == nil
+		if idle {
+			d.forget()
 		}
+		developTex.Unlock()
 
-		pload := map[string]interface{}{
-			"boxfile":    jobs.CombinedBoxfile(false).Node("dev").Parsed,
-			"dev_config": dev_config,
+		if idle {
+			removeContainer(d.UID)
 		}
+	})
+	developTex.Unlock()
+	return nil
+}
+
+// destroy removes the container regardless of the leases held
+func (d *devEnv) destroy() {
+	developTex.Lock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.forget()
+	developTex.Unlock()
 
-		out, err = script.Exec("dev-prepare", "dev1", pload)
+	removeContainer(d.UID)
+}
+
+// forget takes the environment out of the list so the container can be
+// removed without holding the lock. It expects the caller to hold the
+// developTex lock and to call removeContainer once it has let go of it.
+func (d *devEnv) forget() {
+	if devs[d.Name] == d {
+		delete(devs, d.Name)
+	}
+	if removing[d.UID] == nil {
+		removing[d.UID] = make(chan struct{})
+	}
+	d.leases = map[string]bool{}
+	d.Refs = 0
+	d.Running = false
+}
+
+// removeContainer removes the container of an environment that was forgotten
+// and lets anybody waiting to create it again go ahead
+func removeContainer(uid string) {
+	config.Log.Debug("removing %s", uid)
+	if err := docker.RemoveContainer(uid); err != nil {
+		config.Log.Debug("develop remove container: %s", err.Error())
+	}
+
+	developTex.Lock()
+	defer developTex.Unlock()
+
+	if done, ok := removing[uid]; ok {
+		close(done)
+		delete(removing, uid)
+	}
+}
+
+// status returns a copy that is safe to hand to the json encoder
+func (d *devEnv) status() devEnv {
+	developTex.Lock()
+	defer developTex.Unlock()
+
+	return devEnv{
+		Name:        d.Name,
+		UID:         d.UID,
+		Running:     d.Running,
+		Creating:    d.Creating,
+		Refs:        d.Refs,
+		Keep:        d.Keep,
+		IdleTimeout: d.IdleTimeout,
+		LastUsed:    d.LastUsed,
+		DevConfig:   d.DevConfig,
+	}
+}
+
+// ensureContainer creates the dev container for the named environment if
+// there isnt a running one. It reports whether a new container was created. It
+// runs without the developTex lock, the caller marks the environment as
+// creating so nobody else creates it at the same time.
+func ensureContainer(name, uid, dev_config string) (created bool, err error) {
+	container, err := docker.GetContainer(uid)
+	if err == nil && container.State.Running {
+		return false, nil
+	}
+
+	config.Log.Debug("develop container: %+v", container)
+	if container != nil {
+		config.Log.Debug("develop container config: %+v", container.Config)
+		config.Log.Debug("develop container host: %+v", container.HostConfig)
+	}
+
+	if container != nil && !container.State.Running {
+		config.Log.Debug("removing old %s", uid)
+		err = docker.RemoveContainer(container.ID)
 		if err != nil {
-			config.Log.Debug("Failed script output: \n %s", out)
-			config.Log.Debug("out: %s", string(out))
+			config.Log.Debug("develop remove containter: %s", err.Error())
+			return false, err
 		}
+	}
+
+	box := jobs.CombinedBoxfile(false)
+
+	image := "nanobox/build"
+	if stab := box.Node("build").StringValue("stability"); stab != "" {
+		image = image + ":" + stab
+	}
+
+	createConfig := docker.CreateConfig{
+		Image:      image,
+		Category:   "dev",
+		UID:        uid,
+		Name:       name,
+		LibDirs:    jobs.DevLibDirs(name),
+		WorkingDir: jobs.DevWorkingDir(name),
+	}
+
+	container, err = docker.CreateContainer(createConfig)
+	if err != nil {
+		config.Log.Debug("develop create containter: %s", err.Error())
+		return false, err
+	}
+
+	// run the default-user hook to get ssh keys setup
+	out, err := script.Exec("", "default-user", uid, fs.UserPayload())
+	if err != nil {
+		config.Log.Debug("Failed script output: \n %s", out)
+		config.Log.Debug("out: %s", string(out))
+	}
 
+	pload := map[string]interface{}{
+		"boxfile":    jobs.DevBoxfile(name).Parsed,
+		"dev_config": dev_config,
 	}
-	return
+
+	out, err = script.Exec("", "dev-prepare", uid, pload)
+	if err != nil {
+		config.Log.Debug("Failed script output: \n %s", out)
+		config.Log.Debug("out: %s", string(out))
+	}
+
+	return true, nil
 }
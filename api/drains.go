@@ -0,0 +1,46 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/nanobox-io/nanobox-server/util/drains"
+)
+
+// ListDrains shows the log drains added with CreateDrain
+func (api *API) ListDrains(rw http.ResponseWriter, req *http.Request) {
+	writeBody(drains.List(), rw, http.StatusOK)
+}
+
+// CreateDrain starts sending the logs to a file, a syslog server or a http
+// endpoint
+func (api *API) CreateDrain(rw http.ResponseWriter, req *http.Request) {
+	drain := drains.Drain{}
+	if err := parseBody(req, &drain); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	drain, err := drains.Add(drain)
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	writeBody(drain, rw, http.StatusCreated)
+}
+
+// DeleteDrain stops sending the logs to a drain
+func (api *API) DeleteDrain(rw http.ResponseWriter, req *http.Request) {
+	if err := drains.Remove(req.URL.Query().Get(":name")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusNotFound)
+		return
+	}
+
+	writeBody(nil, rw, http.StatusOK)
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nanopack/mist/core"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// keepAlive is how often an idle event stream sends something so proxies and
+// clients dont give up on it
+const keepAlive = 30 * time.Second
+
+// writeTimeout is how long a websocket client has to take a frame
+const writeTimeout = 10 * time.Second
+
+// upgrader lets any origin follow the events, like anybody can connect to mist
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(req *http.Request) bool { return true },
+}
+
+// StreamEvents subscribes to the comma separated mist tags in the tags
+// parameter, eg tags=job,deploy, and streams every message as it comes in.
+// A websocket request gets a text frame per message, any other request gets
+// server-sent events. Either way a message looks like it does on mist:
+//
+//   {"tags":["job","deploy"],"data":"{\"model\":\"Deploy\",...}"}
+func (api *API) StreamEvents(rw http.ResponseWriter, req *http.Request) {
+	tags := []string{}
+	for _, tag := range strings.Split(req.FormValue("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) == 0 {
+		writeBody(map[string]string{"error": "tags are required, eg tags=job,deploy"}, rw, http.StatusBadRequest)
+		return
+	}
+
+	proxy := mist.NewProxy(100)
+	defer proxy.Close()
+	proxy.Subscribe(tags)
+
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		streamWebsocket(proxy, rw, req)
+		return
+	}
+	streamSSE(proxy, rw)
+}
+
+// streamSSE writes the messages as server-sent events until the client goes
+// away
+func streamSSE(proxy *mist.Proxy, rw http.ResponseWriter) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		writeBody(map[string]string{"error": "streaming is not supported"}, rw, http.StatusInternalServerError)
+		return
+	}
+
+	var closed <-chan bool
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case msg, ok := <-proxy.Messages():
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(rw, "data: %s\n\n", b); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// streamWebsocket writes the messages as websocket frames until the client
+// goes away
+func streamWebsocket(proxy *mist.Proxy, rw http.ResponseWriter, req *http.Request) {
+	conn, err := upgrader.Upgrade(rw, req, nil)
+	if err != nil {
+		// the upgrader already responded
+		config.Log.Debug("[nanobox/api] Unable to upgrade the event stream: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// nothing is expected from the client, but reading handles its pings and
+	// tells when it closes
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		case msg, ok := <-proxy.Messages():
+			if !ok {
+				return
+			}
+			b, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+				return
+			}
+		}
+	}
+}
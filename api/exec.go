@@ -12,7 +12,6 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/nanobox-io/nanobox-server/config"
@@ -21,8 +20,6 @@ import (
 	"github.com/nanobox-io/nanobox-server/util/fs"
 )
 
-var execWait = sync.WaitGroup{}
-
 var execKeys = map[string]string{}
 
 func (api *API) LibDirs(rw http.ResponseWriter, req *http.Request) {
@@ -79,15 +76,25 @@ func (api *API) FileChange(rw http.ResponseWriter, req *http.Request) {
 // proxy an exec request to docker. This allows us to have the same
 // exec power but with added security.
 func (api *API) Exec(rw http.ResponseWriter, req *http.Request) {
-	execWait.Add(1)
-	util.Lock()
-	defer execWait.Done()
-	defer util.Unlock()
 	name := req.FormValue("container")
 	// if name == "" {
 	// 	name = "dev1"
 	// }
 
+	cmd := []string{"/bin/bash"}
+	if additionalCmd := req.FormValue("cmd"); additionalCmd != "" {
+		cmd = append(cmd, "-c", additionalCmd)
+	}
+
+	// register the exec as a session so other clients can attach to it, the
+	// client has to know when its session id is taken before the tty starts
+	session, err := newSession(req.FormValue("session"), name, cmd)
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusConflict)
+		return
+	}
+	defer session.close()
+
 	conn, br, err := rw.(http.Hijacker).Hijack()
 	if err != nil {
 		config.Log.Debug("exec hijack error: %s", err.Error())
@@ -97,12 +104,10 @@ func (api *API) Exec(rw http.ResponseWriter, req *http.Request) {
 	}
 	defer conn.Close()
 
-	cmd := []string{"/bin/bash"}
-	if additionalCmd := req.FormValue("cmd"); additionalCmd != "" {
-		cmd = append(cmd, "-c", additionalCmd)
-	}
-
+	// deploys only have to wait for the lookup, not the whole session
+	util.Lock()
 	container, err := docker.GetContainer(name)
+	util.Unlock()
 	if err != nil {
 		config.Log.Debug("exec get container: %s", err.Error())
 		conn.Write([]byte(err.Error()))
@@ -117,7 +122,17 @@ func (api *API) Exec(rw http.ResponseWriter, req *http.Request) {
 		pid := req.FormValue("pid")
 		execKeys[pid] = exec.ID
 		defer delete(execKeys, pid)
-		docker.RunExec(exec, io.MultiReader(br, conn), conn, conn)
+
+		// the requesting connection is always attached read-write before the
+		// exec starts so none of the output is missed, and the exec lives as
+		// long as it does
+		session.add(conn, "rw")
+		go func() {
+			session.read(conn, io.MultiReader(br, conn), "rw")
+			session.output.Close()
+		}()
+
+		docker.RunExec(exec, session.input, session, session)
 	}
 }
 
@@ -0,0 +1,149 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/logquery"
+)
+
+// jobLogLimit is how many lines of a job's output are returned when the
+// request doesnt set a limit
+const jobLogLimit = 10000
+
+// ListLogs returns the latest log messages that pass the filter in the query
+// (see logquery.ParseFilter) as json lines, or as plain text with
+// format=text. With follow=true the connection stays open and new matches are
+// streamed as they come in.
+func (api *API) ListLogs(rw http.ResponseWriter, req *http.Request) {
+	filter, err := logquery.ParseFilter(req.URL.Query())
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	writeLogs(filter, rw, req)
+}
+
+// ListJobLogs returns the output of a single deploy, build or bootstrap. It
+// takes the same parameters as ListLogs, the deploy lines are narrowed down to
+// the job's.
+func (api *API) ListJobLogs(rw http.ResponseWriter, req *http.Request) {
+	filter, err := logquery.ParseFilter(req.URL.Query())
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	filter.Sources = []string{"deploy"}
+	filter.Job = req.URL.Query().Get(":id")
+	if req.FormValue("limit") == "" {
+		filter.Limit = jobLogLimit
+	}
+
+	writeLogs(filter, rw, req)
+}
+
+// writeLogs writes the messages in the archive that pass the filter and keeps
+// following new ones when asked to
+func writeLogs(filter logquery.Filter, rw http.ResponseWriter, req *http.Request) {
+	format := req.FormValue("format")
+	if format != "text" {
+		format = "json"
+	}
+
+	// start listening before reading the archive so nothing falls in between
+	var messages chan logtap.Message
+	if req.FormValue("follow") == "true" {
+		messages = make(chan logtap.Message, 100)
+		tag := "follow-" + newUUID()
+		config.Logtap.AddDrain(tag, func(l logtap.Logger, msg logtap.Message) {
+			if !filter.Match(msg) {
+				return
+			}
+			// drop messages for clients that cant keep up instead of
+			// holding up every other drain
+			select {
+			case messages <- msg:
+			default:
+			}
+		})
+		defer config.Logtap.RemoveDrain(tag)
+	}
+
+	history, err := logquery.Query(config.LogArchive, filter)
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusInternalServerError)
+		return
+	}
+
+	if format == "text" {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	rw.WriteHeader(http.StatusOK)
+
+	for _, msg := range history {
+		io.WriteString(rw, logquery.Format(msg, format))
+	}
+
+	if messages == nil {
+		return
+	}
+
+	flusher, _ := rw.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	var closed <-chan bool
+	if notifier, ok := rw.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	for {
+		select {
+		case <-closed:
+			return
+		case msg := <-messages:
+			if _, err := io.WriteString(rw, logquery.Format(msg, format)); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// DeleteLogs clears the log history of the comma separated sources in the
+// source parameter, or all of it without one, and gives the space back
+func (api *API) DeleteLogs(rw http.ResponseWriter, req *http.Request) {
+	sources := []string{}
+	for _, source := range strings.Split(req.FormValue("source"), ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+
+	if err := config.LogArchive.Clear(sources...); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusInternalServerError)
+		return
+	}
+	if err := config.LogArchive.Compact(); err != nil {
+		config.Log.Error("[nanobox/api] Unable to compact the logs: %s", err.Error())
+	}
+
+	writeBody(nil, rw, http.StatusOK)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/nanobox-io/nanobox-server/jobs"
+)
+
+// CreateRoute adds a temporary route to a container port or a host address
+func (api *API) CreateRoute(rw http.ResponseWriter, req *http.Request) {
+	route := jobs.AdhocRoute{}
+	if err := parseBody(req, &route); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	route, err := jobs.AddAdhocRoute(route)
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	writeBody(route, rw, http.StatusCreated)
+}
+
+// DeleteRoute removes a route added with CreateRoute
+func (api *API) DeleteRoute(rw http.ResponseWriter, req *http.Request) {
+	if err := jobs.RemoveAdhocRoute(req.URL.Query().Get(":id")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusNotFound)
+		return
+	}
+
+	writeBody(nil, rw, http.StatusOK)
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/nanobox-io/nanobox-router"
 	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/jobs"
 	"github.com/nanobox-io/nanobox-server/util"
 	"github.com/nanobox-io/nanobox-server/util/docker"
 	"github.com/nanobox-io/nanobox-server/util/script"
@@ -32,14 +33,30 @@ type Service struct {
 }
 
 func (api *API) ListRoutes(rw http.ResponseWriter, req *http.Request) {
+	// only show the routes added through the api
+	if req.FormValue("adhoc") == "true" {
+		writeBody(jobs.AdhocRoutes(), rw, http.StatusOK)
+		return
+	}
+
 	writeBody(router.Routes(), rw, http.StatusOK)
 }
 
+// ListRoutesHealth shows the routes and how each of their targets is doing
+func (api *API) ListRoutesHealth(rw http.ResponseWriter, req *http.Request) {
+	writeBody(jobs.RoutesHealth(), rw, http.StatusOK)
+}
+
 func (api *API) ListVips(rw http.ResponseWriter, req *http.Request) {
 	vips, _ := util.ListVips()
 	writeBody(vips, rw, http.StatusOK)
 }
 
+// ListPorts shows which service each host port belongs to
+func (api *API) ListPorts(rw http.ResponseWriter, req *http.Request) {
+	writeBody(util.PortAllocations(), rw, http.StatusOK)
+}
+
 // ListServices
 func (api *API) ListServices(rw http.ResponseWriter, req *http.Request) {
 
@@ -73,7 +90,7 @@ func (api *API) ListServices(rw http.ResponseWriter, req *http.Request) {
 		service.Ports = ports
 
 		// run environment hook (blocking)
-		if out, err := script.Exec("environment", container.ID, nil); err == nil {
+		if out, err := script.Exec("", "environment", container.ID, nil); err == nil {
 			config.Log.Info("getting port data: %s", out)
 			uidlessEvar := map[string]string{}
 			if err := json.Unmarshal(out, &uidlessEvar); err == nil {
@@ -0,0 +1,246 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// an execSession is a running exec that more than one connection can
+// watch. everything the exec writes is fanned out to every attached
+// connection and anything a read-write connection sends is fed into the
+// exec's stdin.
+type execSession struct {
+	ID        string
+	Container string
+	Cmd       []string
+	CreatedAt time.Time
+
+	input  *io.PipeReader
+	output *io.PipeWriter
+
+	mutex sync.Mutex
+	conns map[net.Conn]*viewer
+}
+
+// a viewer is a connection attached to a session. Output is queued for each
+// viewer and written by its own goroutine so a slow connection can't hold up
+// the exec or the other viewers.
+type viewer struct {
+	mode  string
+	queue chan []byte
+	done  chan struct{}
+}
+
+// viewerQueue is how many chunks of output a viewer can fall behind before it
+// is dropped
+const viewerQueue = 256
+
+// flushTimeout is how long a closing session waits for a viewer to take the
+// rest of the output
+const flushTimeout = 5 * time.Second
+
+var sessionTex = sync.Mutex{}
+
+var sessions = map[string]*execSession{}
+
+// newSession creates and registers a session for an exec that is about to be
+// started. The id is taken from the requesting client when it provides one so
+// it can share it with the people it wants to attach, it is an error when
+// another session already has it.
+func newSession(id, container string, cmd []string) (*execSession, error) {
+	sessionTex.Lock()
+	defer sessionTex.Unlock()
+
+	if sessions[id] != nil {
+		return nil, fmt.Errorf("session %s is already in use", id)
+	}
+	if id == "" {
+		id = newUUID()
+	}
+
+	input, output := io.Pipe()
+	session := &execSession{
+		ID:        id,
+		Container: container,
+		Cmd:       cmd,
+		CreatedAt: time.Now(),
+		input:     input,
+		output:    output,
+		conns:     map[net.Conn]*viewer{},
+	}
+	sessions[id] = session
+	return session, nil
+}
+
+// getSession
+func getSession(id string) *execSession {
+	sessionTex.Lock()
+	defer sessionTex.Unlock()
+	return sessions[id]
+}
+
+// Write queues the exec output for every attached connection. A connection
+// that has fallen too far behind is dropped from the session.
+func (s *execSession) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn, v := range s.conns {
+		select {
+		case v.queue <- append([]byte{}, p...):
+		default:
+			config.Log.Debug("session %s dropping a connection that fell behind", s.ID)
+			delete(s.conns, conn)
+			close(v.queue)
+			conn.Close()
+		}
+	}
+	return len(p), nil
+}
+
+// attach adds a connection to the session and blocks until the connection
+// goes away
+func (s *execSession) attach(conn net.Conn, in io.Reader, mode string) {
+	s.add(conn, mode)
+	s.read(conn, in, mode)
+}
+
+// add starts sending the session output to a connection
+func (s *execSession) add(conn net.Conn, mode string) {
+	v := &viewer{
+		mode:  mode,
+		queue: make(chan []byte, viewerQueue),
+		done:  make(chan struct{}),
+	}
+
+	s.mutex.Lock()
+	s.conns[conn] = v
+	s.mutex.Unlock()
+
+	go func() {
+		defer close(v.done)
+		for p := range v.queue {
+			if _, err := conn.Write(p); err != nil {
+				config.Log.Debug("session %s write error: %s", s.ID, err.Error())
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
+
+// read blocks until the connection goes away. Input from read-write
+// connections is forwarded to the exec, input from read-only connections is
+// thrown away.
+func (s *execSession) read(conn net.Conn, in io.Reader, mode string) {
+	defer s.detach(conn)
+
+	if mode == "rw" {
+		io.Copy(s.output, in)
+		return
+	}
+	io.Copy(ioutil.Discard, in)
+}
+
+// detach
+func (s *execSession) detach(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if v, ok := s.conns[conn]; ok {
+		delete(s.conns, conn)
+		close(v.queue)
+	}
+}
+
+// close ends the session, lets everyone still attached take the rest of the
+// output and hangs up on them
+func (s *execSession) close() {
+	sessionTex.Lock()
+	delete(sessions, s.ID)
+	sessionTex.Unlock()
+
+	s.output.Close()
+
+	s.mutex.Lock()
+	conns := s.conns
+	s.conns = map[net.Conn]*viewer{}
+	s.mutex.Unlock()
+
+	for conn, v := range conns {
+		close(v.queue)
+		conn.SetWriteDeadline(time.Now().Add(flushTimeout))
+		<-v.done
+		conn.Close()
+	}
+}
+
+// ListSessions shows the exec sessions that can be attached to
+func (api *API) ListSessions(rw http.ResponseWriter, req *http.Request) {
+	type sessionInfo struct {
+		ID        string
+		Container string
+		Cmd       []string
+		CreatedAt time.Time
+		Attached  int
+	}
+
+	sessionTex.Lock()
+	list := []sessionInfo{}
+	for _, session := range sessions {
+		session.mutex.Lock()
+		list = append(list, sessionInfo{
+			ID:        session.ID,
+			Container: session.Container,
+			Cmd:       session.Cmd,
+			CreatedAt: session.CreatedAt,
+			Attached:  len(session.conns),
+		})
+		session.mutex.Unlock()
+	}
+	sessionTex.Unlock()
+
+	writeBody(list, rw, http.StatusOK)
+}
+
+// AttachSession connects another client to an existing exec session. The
+// mode can be 'ro' to only watch the output or 'rw' to also type into it.
+func (api *API) AttachSession(rw http.ResponseWriter, req *http.Request) {
+	session := getSession(req.FormValue("id"))
+	if session == nil {
+		writeBody(map[string]string{"error": "no such session"}, rw, http.StatusNotFound)
+		return
+	}
+
+	mode := req.FormValue("mode")
+	if mode != "rw" {
+		mode = "ro"
+	}
+
+	conn, br, err := rw.(http.Hijacker).Hijack()
+	if err != nil {
+		config.Log.Debug("attach hijack error: %s", err.Error())
+		rw.WriteHeader(http.StatusInternalServerError)
+		rw.Write([]byte(err.Error()))
+		return
+	}
+	defer conn.Close()
+
+	// Flush the options to make sure the client sets the raw mode
+	conn.Write([]byte{})
+
+	session.attach(conn, io.MultiReader(br, conn), mode)
+}
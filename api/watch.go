@@ -0,0 +1,231 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobox-io/nanobox-golang-stylish"
+	"github.com/nanopack/mist/core"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/jobs"
+	"github.com/nanobox-io/nanobox-server/util"
+	"github.com/nanobox-io/nanobox-server/util/watch"
+)
+
+var watcher *watch.Watcher
+
+var watchTex = sync.Mutex{}
+
+// watchBuild is true while we are in watch mode and rebuilding on changes
+var watchBuild = false
+
+var rebuildTex = sync.Mutex{}
+
+var rebuildQueued = false
+
+// startWatcher watches the app code for changes when the Boxfile turns on the
+// file_watcher option in the dev node. Changes are published to mist and
+// watch_build puts the server in watch mode so every change is rebuilt.
+func (api *API) startWatcher() {
+	dev := jobs.CombinedBoxfile(false).Node("dev")
+	if !dev.BoolValue("file_watcher") && !dev.BoolValue("watch_build") {
+		return
+	}
+
+	watchTex.Lock()
+	defer watchTex.Unlock()
+
+	watchBuild = dev.BoolValue("watch_build")
+	if err := api.ensureWatcher(""); err != nil {
+		config.Log.Error("[nanobox/api] Unable to watch files: %s", err.Error())
+	}
+}
+
+// StartWatch turns on watch mode
+func (api *API) StartWatch(rw http.ResponseWriter, req *http.Request) {
+	watchTex.Lock()
+	defer watchTex.Unlock()
+
+	if err := api.ensureWatcher(req.FormValue("debounce")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusInternalServerError)
+		return
+	}
+	watchBuild = true
+
+	writeBody(watchStatus(), rw, http.StatusOK)
+}
+
+// ShowWatch
+func (api *API) ShowWatch(rw http.ResponseWriter, req *http.Request) {
+	watchTex.Lock()
+	defer watchTex.Unlock()
+
+	writeBody(watchStatus(), rw, http.StatusOK)
+}
+
+// StopWatch turns off watch mode. The watcher keeps publishing changes if the
+// Boxfile asked for it.
+func (api *API) StopWatch(rw http.ResponseWriter, req *http.Request) {
+	watchTex.Lock()
+	defer watchTex.Unlock()
+
+	watchBuild = false
+	if watcher != nil && !jobs.CombinedBoxfile(false).Node("dev").BoolValue("file_watcher") {
+		watcher.Stop()
+		watcher = nil
+	}
+
+	writeBody(watchStatus(), rw, http.StatusOK)
+}
+
+// watchStatus expects the caller to hold the watchTex lock
+func watchStatus() map[string]interface{} {
+	status := map[string]interface{}{
+		"watching": watcher != nil,
+		"build":    watchBuild,
+	}
+	if watcher != nil {
+		status["debounce"] = watcher.DebounceDelay().String()
+		status["ignore"] = watcher.Ignore
+	}
+	return status
+}
+
+// ensureWatcher starts the watcher if it isnt running. It expects the caller
+// to hold the watchTex lock.
+func (api *API) ensureWatcher(debounce string) error {
+	if watcher != nil {
+		if d, err := time.ParseDuration(debounce); err == nil {
+			watcher.SetDebounce(d)
+		}
+		return nil
+	}
+
+	dev := jobs.CombinedBoxfile(false).Node("dev")
+	w := watch.New(config.MountFolder + "code/" + config.App() + "/")
+
+	if ignores, ok := dev.Value("watch_ignore").([]interface{}); ok {
+		for _, ignore := range ignores {
+			if str, ok := ignore.(string); ok {
+				w.Ignore = append(w.Ignore, str)
+			}
+		}
+	}
+
+	if debounce == "" {
+		debounce = dev.StringValue("watch_debounce")
+	}
+	if d, err := time.ParseDuration(debounce); err == nil {
+		w.SetDebounce(d)
+	}
+
+	w.Handler = api.filesChanged
+
+	if err := w.Start(); err != nil {
+		return err
+	}
+	watcher = w
+	return nil
+}
+
+// filesChanged is called by the watcher with every batch of changes
+func (api *API) filesChanged(paths []string) {
+	publishChanges(paths)
+
+	watchTex.Lock()
+	build := watchBuild
+	watchTex.Unlock()
+
+	if !build {
+		return
+	}
+
+	// changes to the lib dirs are build output, rebuilding for them would
+	// only get us into a loop
+	changed := outsideLibDirs(paths)
+	if len(changed) == 0 {
+		return
+	}
+
+	util.LogInfo(stylish.Bullet("Detected %d changed file(s)", len(changed)))
+	for i, path := range changed {
+		if i == 5 {
+			util.LogInfo(stylish.SubBullet("- and %d more", len(changed)-i))
+			break
+		}
+		util.LogInfo(stylish.SubBullet("- %s", path))
+	}
+
+	api.queueRebuild()
+}
+
+// outsideLibDirs drops every path that lives in one of the boxfile lib_dirs
+func outsideLibDirs(paths []string) []string {
+	libDirs, _ := jobs.CombinedBoxfile(false).Node("build").Value("lib_dirs").([]interface{})
+
+	rtn := []string{}
+	for _, path := range paths {
+		inLibDir := false
+		for _, libDir := range libDirs {
+			dir, ok := libDir.(string)
+			dir = strings.Trim(dir, "/")
+			if ok && dir != "" && (path == dir || strings.HasPrefix(path, dir+"/")) {
+				inLibDir = true
+				break
+			}
+		}
+		if !inLibDir {
+			rtn = append(rtn, path)
+		}
+	}
+	return rtn
+}
+
+// publishChanges lets anybody listening on mist know which files changed
+func publishChanges(paths []string) {
+	b, err := json.Marshal(map[string]interface{}{"action": "change", "paths": paths})
+	if err != nil {
+		return
+	}
+	mist.Publish([]string{"file", "change"}, string(b))
+}
+
+// queueRebuild queues a build unless there is one waiting to start already;
+// that one will pick up these changes as well
+func (api *API) queueRebuild() {
+	rebuildTex.Lock()
+	defer rebuildTex.Unlock()
+
+	if rebuildQueued {
+		return
+	}
+	rebuildQueued = true
+
+	api.Worker.QueueAndProcess(&rebuild{})
+}
+
+// rebuild is a build triggered by file changes
+type rebuild struct{}
+
+//
+func (r *rebuild) Process() {
+	// anything changing from here on needs another build
+	rebuildTex.Lock()
+	rebuildQueued = false
+	rebuildTex.Unlock()
+
+	util.LogInfo(stylish.Bullet("Rebuilding..."))
+
+	build := jobs.Build{ID: newUUID()}
+	build.Process()
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/nanobox-io/nanobox-server/util/webhooks"
+)
+
+// ListWebhooks shows the registered webhooks without their secrets
+func (api *API) ListWebhooks(rw http.ResponseWriter, req *http.Request) {
+	writeBody(webhooks.List(), rw, http.StatusOK)
+}
+
+// CreateWebhook registers a url to be told when jobs finish. The response has
+// the secret the deliveries are signed with.
+func (api *API) CreateWebhook(rw http.ResponseWriter, req *http.Request) {
+	hook := webhooks.Webhook{}
+	if err := parseBody(req, &hook); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	hook, err := webhooks.Register(hook)
+	if err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusBadRequest)
+		return
+	}
+
+	writeBody(hook, rw, http.StatusCreated)
+}
+
+// DeleteWebhook
+func (api *API) DeleteWebhook(rw http.ResponseWriter, req *http.Request) {
+	if err := webhooks.Remove(req.URL.Query().Get(":id")); err != nil {
+		writeBody(map[string]string{"error": err.Error()}, rw, http.StatusNotFound)
+		return
+	}
+
+	writeBody(nil, rw, http.StatusOK)
+}
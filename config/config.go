@@ -11,13 +11,16 @@ import (
 	"errors"
 	"io/ioutil"
 	"net"
-	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jcelliott/lumber"
 
 	"github.com/nanobox-io/nanobox-logtap"
+	"github.com/nanobox-io/nanobox-server/util/archive"
+	"github.com/nanobox-io/nanobox-server/util/cert"
 )
 
 //
@@ -29,10 +32,32 @@ var (
 	MountFolder string
 	DockerMount string
 	CachedBox   string
+	CertDir     string
 
-	Log        lumber.Logger
-	Logtap     *logtap.Logtap
-	LogHandler http.HandlerFunc
+	// PortRegistry is where the host ports given to services are kept
+	PortRegistry string
+
+	// DrainRegistry is where the log drains added at runtime are kept
+	DrainRegistry string
+
+	// DrainDir is the only place file drains can write to
+	DrainDir string
+
+	// WebhookRegistry is where the job webhooks are kept
+	WebhookRegistry string
+
+	// Forwarder picks how ports are forwarded: ipvs, userspace or auto
+	Forwarder string
+
+	// where the log history is kept and how much of it
+	LogArchivePath string
+	LogMaxAge      time.Duration
+	LogMaxSize     int64
+
+	Log           lumber.Logger
+	Logtap        *logtap.Logtap
+	LogArchive    *archive.Archive
+	CertAuthority *cert.Authority
 )
 
 //
@@ -40,6 +65,11 @@ func init() {
 	MountFolder = "/vagrant/"
 	DockerMount = "/mnt/"
 	CachedBox = DockerMount + "sda/var/nanobox/Boxfile.cache"
+	CertDir = DockerMount + "sda/var/nanobox/certs/"
+	PortRegistry = DockerMount + "sda/var/nanobox/ports.json"
+	DrainRegistry = DockerMount + "sda/var/nanobox/drains.json"
+	DrainDir = DockerMount + "sda/var/nanobox/drains/"
+	WebhookRegistry = DockerMount + "sda/var/nanobox/webhooks.json"
 	// create an error object
 	var err error
 	levelEnv := os.Getenv("NANOBOX_LOGLEVEL")
@@ -48,11 +78,30 @@ func init() {
 	}
 	Log = lumber.NewConsoleLogger(lumber.LvlInt(levelEnv))
 
+	Forwarder = os.Getenv("NANOBOX_FORWARDER")
+	if Forwarder == "" {
+		Forwarder = "auto"
+	}
+
+	LogArchivePath = os.Getenv("NANOBOX_LOG_ARCHIVE")
+	if LogArchivePath == "" {
+		LogArchivePath = DockerMount + "sda/var/nanobox/logs.db"
+	}
+	LogMaxAge = 7 * 24 * time.Hour
+	if d, err := time.ParseDuration(os.Getenv("NANOBOX_LOG_MAX_AGE")); err == nil {
+		LogMaxAge = d
+	}
+	LogMaxSize = 100 << 20
+	if size, err := strconv.ParseInt(os.Getenv("NANOBOX_LOG_MAX_SIZE"), 10, 64); err == nil {
+		LogMaxSize = size
+	}
+
 	//
 	Ports = map[string]string{
-		"api":    ":1757",
-		"logtap": ":514",
-		"router": "60000",
+		"api":        ":1757",
+		"logtap":     ":514",
+		"router":     "60000",
+		"router_tls": "60443",
 	}
 
 	IP, err = externalIP()
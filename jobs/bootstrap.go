@@ -16,6 +16,9 @@ import (
 	"github.com/nanobox-io/nanobox-server/util/script"
 )
 
+// bootstrapSteps are the stages a bootstrap reports its progress in
+var bootstrapSteps = []string{"directories", "build-image", "build-container", "bootstrap"}
+
 //
 type Bootstrap struct {
 	ID     string
@@ -24,27 +27,32 @@ type Bootstrap struct {
 
 // Bootstrap the code according to the engine provided
 func (j *Bootstrap) Process() {
+	log := util.JobLog{ID: j.ID}
+	progress := util.NewProgress("Bootstrap", j.ID, bootstrapSteps...)
 
 	// Make sure we have the directories
-	util.LogDebug(stylish.Bullet("Ensure directories exist on host..."))
+	progress.Step("directories")
+	log.Debug(stylish.Bullet("Ensure directories exist on host..."))
 	if err := fs.CreateDirs(); err != nil {
-		util.HandleError(stylish.Error("Failed to create dirs", err.Error()))
-		util.UpdateStatus(j, "errored")
+		log.HandleError(stylish.Error("Failed to create dirs", err.Error()))
+		progress.Fail(err.Error())
 		return
 	}
 
 	// if the build image doesn't exist it needs to be downloaded
+	progress.Step("build-image")
 	if !docker.ImageExists("nanobox/build") {
-		util.LogInfo(stylish.Bullet("Pulling the latest build image (this will take awhile)... "))
+		log.Info(stylish.Bullet("Pulling the latest build image (this will take awhile)... "))
 		docker.InstallImage("nanobox/build")
 	}
 
 	// create a build container
-	util.LogInfo(stylish.Bullet("Creating build container..."))
+	progress.Step("build-container")
+	log.Info(stylish.Bullet("Creating build container..."))
 	_, err := docker.CreateContainer(docker.CreateConfig{Image: "nanobox/build", Category: "bootstrap", UID: "bootstrap1"})
 	if err != nil {
-		util.HandleError(stylish.Error("Failed to create build container", err.Error()))
-		util.UpdateStatus(j, "errored")
+		log.HandleError(stylish.Error("Failed to create build container", err.Error()))
+		progress.Fail(err.Error())
 		return
 	}
 
@@ -56,12 +64,15 @@ func (j *Bootstrap) Process() {
 	}
 
 	// run configure hook (blocking)
-	if _, err := script.Exec("default-bootstrap", "bootstrap1", payload); err != nil {
-		util.HandleError(stylish.Error("Failed to run bootstrap hook", err.Error()))
-		util.UpdateStatus(j, "errored")
+	progress.Step("bootstrap")
+	if _, err := script.Exec(j.ID, "default-bootstrap", "bootstrap1", payload); err != nil {
+		log.HandleError(stylish.Error("Failed to run bootstrap hook", err.Error()))
+		docker.RemoveContainer("bootstrap1")
+		progress.Fail(err.Error())
+		return
 	}
 
 	docker.RemoveContainer("bootstrap1")
 
-	util.UpdateStatus(j, "complete")
+	progress.Finish("complete")
 }
@@ -26,15 +26,25 @@ type Build struct {
 	payload map[string]interface{}
 }
 
+// buildSteps are the stages a build reports its progress in
+var buildSteps = []string{"environment", "build", "restart"}
+
+// log tags the build output with the build id
+func (j *Build) log() util.JobLog {
+	return util.JobLog{ID: j.ID}
+}
+
 // Proccess syncronies your docker containers with the boxfile specification
 func (j *Build) Process() {
 	// add a lock so the service wont go down whil im running
 	util.Lock()
 	defer util.Unlock()
 
+	progress := util.NewProgress("Build", j.ID, buildSteps...)
+
 	_, err := docker.InspectContainer("build1")
 	if err != nil {
-		util.UpdateStatus(j, "unavailable")
+		progress.Finish("unavailable")
 		return
 	}
 
@@ -51,6 +61,8 @@ func (j *Build) Process() {
 	}
 
 	// grab the environment data from all service containers
+	progress.Step("environment")
+	j.log().Info(stylish.Bullet("Gathering environment variables"))
 
 	worker := worker.New()
 	worker.Blocking = true
@@ -62,7 +74,7 @@ func (j *Build) Process() {
 	serviceContainers, _ := docker.ListContainers("service")
 	for _, container := range serviceContainers {
 
-		s := ServiceEnv{UID: container.Config.Labels["uid"]}
+		s := ServiceEnv{UID: container.Config.Labels["uid"], Job: j.ID}
 		serviceEnvs = append(serviceEnvs, &s)
 
 		worker.Queue(&s)
@@ -72,12 +84,12 @@ func (j *Build) Process() {
 
 	evars := DefaultEVars(*box)
 
-	failedEnv := false
+	failedEnvs := []string{}
 	for _, env := range serviceEnvs {
 		if !env.Success {
-			util.HandleError(stylish.ErrorHead("Failed to configure %v's environment variables", env.UID))
-			util.HandleError(stylish.ErrorBody(""))
-			failedEnv = true
+			j.log().HandleError(stylish.ErrorHead("Failed to configure %v's environment variables", env.UID))
+			j.log().HandleError(stylish.ErrorBody(""))
+			failedEnvs = append(failedEnvs, env.UID)
 			continue
 		}
 
@@ -85,19 +97,22 @@ func (j *Build) Process() {
 			evars[strings.ToUpper(env.UID+"_"+key)] = val
 		}
 	}
-	if failedEnv {
-		util.UpdateStatus(j, "errored")
-
+	if len(failedEnvs) > 0 {
+		progress.Fail("failed to configure the environment variables of " + strings.Join(failedEnvs, ", "))
 		return
 	}
 
 	j.payload["env"] = evars
 
+	progress.Step("build")
+	j.log().Info(stylish.Bullet("Building code"))
 	if err := j.RunBuild(); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
+	progress.Step("restart")
+
 	restarts := []*Restart{}
 
 	// find code containers and run the restart hook
@@ -106,7 +121,7 @@ func (j *Build) Process() {
 
 		uid := container.Config.Labels["uid"]
 
-		r := Restart{UID: uid}
+		r := Restart{UID: uid, Job: j.ID}
 		restarts = append(restarts, &r)
 		worker.Queue(&r)
 	}
@@ -116,34 +131,39 @@ func (j *Build) Process() {
 	// ensure all services started correctly before continuing
 	for _, restart := range restarts {
 		if !restart.Success {
-			util.HandleError(stylish.ErrorHead("Failed to restart %v", restart.UID))
-			util.HandleError(stylish.ErrorBody("unsuccessful restart"))
-			util.UpdateStatus(j, "errored")
+			j.log().HandleError(stylish.ErrorHead("Failed to restart %v", restart.UID))
+			j.log().HandleError(stylish.ErrorBody("unsuccessful restart"))
+			progress.Fail("failed to restart " + restart.UID)
 			return
 		}
 	}
 
-	util.UpdateStatus(j, "complete")
+	j.log().Info(stylish.Bullet("Build complete"))
+	progress.Finish("complete")
 }
 
 func (j *Build) RunBuild() error {
 	// run sync hook (blocking)
-	if _, err := script.Exec("default-sync", "build1", j.payload); err != nil {
+	j.log().Debug(stylish.SubBullet("- Syncing code"))
+	if _, err := script.Exec(j.ID, "default-sync", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run build hook (blocking)
-	if _, err := script.Exec("default-build", "build1", j.payload); err != nil {
+	j.log().Debug(stylish.SubBullet("- Running build hook"))
+	if _, err := script.Exec(j.ID, "default-build", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run publish hook (blocking)
-	if _, err := script.Exec("default-publish", "build1", j.payload); err != nil {
+	j.log().Debug(stylish.SubBullet("- Publishing build"))
+	if _, err := script.Exec(j.ID, "default-publish", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run cleanup script (blocking)
-	if _, err := script.Exec("default-cleanup", "build1", j.payload); err != nil {
+	j.log().Debug(stylish.SubBullet("- Cleaning up"))
+	if _, err := script.Exec(j.ID, "default-cleanup", "build1", j.payload); err != nil {
 		return err
 	}
 
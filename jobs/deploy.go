@@ -21,6 +21,7 @@ import (
 	"github.com/nanobox-io/nanobox-server/util"
 	"github.com/nanobox-io/nanobox-server/util/docker"
 	"github.com/nanobox-io/nanobox-server/util/fs"
+	"github.com/nanobox-io/nanobox-server/util/pages"
 	"github.com/nanobox-io/nanobox-server/util/script"
 	"github.com/nanobox-io/nanobox-server/util/worker"
 )
@@ -34,6 +35,25 @@ type Deploy struct {
 	payload map[string]interface{}
 }
 
+// deploySteps are the stages a deploy reports its progress in
+var deploySteps = []string{
+	"cleaning",
+	"build-container",
+	"services",
+	"environment",
+	"build",
+	"code",
+	"before-deploy",
+	"ports",
+	"routes",
+	"after-deploy",
+}
+
+// log tags the deploy output with the deploy id
+func (j *Deploy) log() util.JobLog {
+	return util.JobLog{ID: j.ID}
+}
+
 // Proccess syncronies your docker containers with the boxfile specification
 func (j *Deploy) Process() {
 	// add a lock so the service wont go down whil im running
@@ -41,26 +61,32 @@ func (j *Deploy) Process() {
 	defer util.Unlock()
 
 	// set routing to watch logs
-	router.ErrorHandler = router.DeployInProgress{}
+	pages.SetJob(j.ID)
+	loadPages(*UserBoxfile(true))
+	router.ErrorHandler = pages.Page{Name: pages.Deploying}
+
+	progress := util.NewProgress("Deploy", j.ID, deploySteps...)
 
 	// remove all code containers
-	util.LogInfo(stylish.Bullet("Cleaning containers"))
+	progress.Step("cleaning")
+	j.log().Info(stylish.Bullet("Cleaning containers"))
 	if err := j.RemoveOldContainers(); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
 	if err := j.SetupFS(); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
 	// parse the boxfile
-	util.LogDebug(stylish.Bullet("Parsing Boxfile"))
+	j.log().Debug(stylish.Bullet("Parsing Boxfile"))
 	box := UserBoxfile(true)
 
+	progress.Step("build-container")
 	if err := j.CreateBuildContainer(box.Node("build")); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
@@ -77,7 +103,7 @@ func (j *Deploy) Process() {
 	j.payload["env"] = DefaultEVars(*box)
 
 	if err := j.SetupBuild(); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
@@ -93,19 +119,22 @@ func (j *Deploy) Process() {
 
 	j.payload["boxfile"] = box.Node("build").Parsed
 
+	progress.Step("services")
+
 	// remove any containers no longer in the boxfile
 	// this will also remove any services where the boxfile has been modified since last deploy
-	util.LogDebug(stylish.Bullet("Removing old containers..."))
+	j.log().Debug(stylish.Bullet("Removing old containers..."))
 	serviceContainers, _ := docker.ListContainers("service")
 	for _, container := range serviceContainers {
 		if !box.Node(container.Config.Labels["uid"]).Valid {
-			util.LogDebug(stylish.SubBullet("- removing " + container.Config.Labels["uid"]))
+			j.log().Debug(stylish.SubBullet("- removing " + container.Config.Labels["uid"]))
 			util.RemoveForward(container.NetworkSettings.IPAddress)
+			util.ReleasePort(container.Config.Labels["uid"])
 			docker.RemoveContainer(container.ID)
 			continue
 		}
 		if !reflect.DeepEqual(box.Node(container.Config.Labels["uid"]), oldCombinedBox.Node(container.Config.Labels["uid"])) {
-			util.LogDebug(stylish.SubBullet("- replacing " + container.Config.Labels["uid"]))
+			j.log().Debug(stylish.SubBullet("- replacing " + container.Config.Labels["uid"]))
 			util.RemoveForward(container.NetworkSettings.IPAddress)
 			docker.RemoveContainer(container.ID)
 		}
@@ -125,6 +154,7 @@ func (j *Deploy) Process() {
 				Boxfile: box.Node(node),
 				UID:     node,
 				EVars:   map[string]string{},
+				Job:     j.ID,
 			}
 
 			serviceStarts = append(serviceStarts, &s)
@@ -134,7 +164,7 @@ func (j *Deploy) Process() {
 	}
 
 	if worker.Count() > 0 {
-		util.LogInfo(stylish.Bullet("Launching data services"))
+		j.log().Info(stylish.Bullet("Launching data services"))
 	}
 
 	// we dont want service starts to be concurrent here for messaging
@@ -143,21 +173,22 @@ func (j *Deploy) Process() {
 	// make the worker concurrent from here on
 	worker.Concurrent = true
 
-	failedStart := false
+	failedStarts := []string{}
 	// ensure all services started correctly before continuing
 	for _, starts := range serviceStarts {
 		if !starts.Success {
-			util.HandleError(stylish.ErrorHead("Failed to start %v", starts.UID))
-			util.HandleError(stylish.ErrorBody(""))
-			failedStart = true
+			j.log().HandleError(stylish.ErrorHead("Failed to start %v", starts.UID))
+			j.log().HandleError(stylish.ErrorBody(""))
+			failedStarts = append(failedStarts, starts.UID)
 		}
 	}
-	if failedStart {
-		util.UpdateStatus(j, "errored")
+	if len(failedStarts) > 0 {
+		progress.Fail("failed to start " + strings.Join(failedStarts, ", "))
 		return
 	}
 
 	// grab the environment data from all service containers
+	progress.Step("environment")
 	evars := j.payload["env"].(map[string]string)
 
 	// clear out the old ports from the previous deploy
@@ -169,7 +200,7 @@ func (j *Deploy) Process() {
 	serviceContainers, _ = docker.ListContainers("service")
 	for _, container := range serviceContainers {
 
-		s := ServiceEnv{UID: container.Config.Labels["uid"], FirstTime: true}
+		s := ServiceEnv{UID: container.Config.Labels["uid"], FirstTime: true, Job: j.ID}
 		for _, serviceStart := range serviceStarts {
 			if serviceStart.UID == s.UID {
 				s.FirstTime = true
@@ -182,12 +213,12 @@ func (j *Deploy) Process() {
 
 	worker.Process()
 
-	failedEnv := false
+	failedEnvs := []string{}
 	for _, env := range serviceEnvs {
 		if !env.Success {
-			util.HandleError(stylish.ErrorHead("Failed to configure %v's environment variables", env.UID))
-			util.HandleError(stylish.ErrorBody(""))
-			failedEnv = true
+			j.log().HandleError(stylish.ErrorHead("Failed to configure %v's environment variables", env.UID))
+			j.log().HandleError(stylish.ErrorBody(""))
+			failedEnvs = append(failedEnvs, env.UID)
 			continue
 		}
 
@@ -195,19 +226,21 @@ func (j *Deploy) Process() {
 			evars[strings.ToUpper(env.UID+"_"+key)] = val
 		}
 	}
-	if failedEnv {
-		util.UpdateStatus(j, "errored")
-
+	if len(failedEnvs) > 0 {
+		progress.Fail("failed to configure the environment variables of " + strings.Join(failedEnvs, ", "))
 		return
 	}
 
 	j.payload["env"] = evars
 
+	progress.Step("build")
 	if err := j.RunBuild(); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
+	progress.Step("code")
+
 	// we will only create new code nodes if we are
 	// supposed to be running
 	if j.Run {
@@ -221,6 +254,7 @@ func (j *Deploy) Process() {
 					Boxfile: box.Node(node),
 					UID:     node,
 					EVars:   evars,
+					Job:     j.ID,
 				}
 
 				codeServices = append(codeServices, &s)
@@ -228,7 +262,7 @@ func (j *Deploy) Process() {
 				worker.Queue(&s)
 			}
 			if worker.Count() > 0 {
-				util.LogInfo(stylish.Bullet("Launching Code services"))
+				j.log().Info(stylish.Bullet("Launching Code services"))
 			}
 		}
 
@@ -236,43 +270,47 @@ func (j *Deploy) Process() {
 
 		for _, serv := range codeServices {
 			if !serv.Success {
-				util.HandleError("A Service was not started correctly (" + serv.UID + ")")
-				util.UpdateStatus(j, "errored")
+				j.log().HandleError("A Service was not started correctly (" + serv.UID + ")")
+				progress.Fail("failed to start " + serv.UID)
 				return
 			}
 		}
 	}
 
-	util.LogDebug(stylish.Bullet("Running before deploy scripts..."))
+	progress.Step("before-deploy")
+	j.log().Debug(stylish.Bullet("Running before deploy scripts..."))
 
 	if err := j.RunDeployScripts("before", *box); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
 	// configure the port forwards per service
+	progress.Step("ports")
 	if err := configurePorts(*box); err != nil {
-		util.HandleError(stylish.Error("Failed to configure Ports", err.Error()))
-		util.UpdateStatus(j, "errored")
+		j.log().HandleError(stylish.Error("Failed to configure Ports", err.Error()))
+		progress.Fail(err.Error())
 		return
 	}
 
 	// configure the routing mesh for any web services
+	progress.Step("routes")
 	if err := configureRoutes(*box); err != nil {
-		util.HandleError(stylish.Error("Failed to configure Routes", err.Error()))
-		util.UpdateStatus(j, "errored")
+		j.log().HandleError(stylish.Error("Failed to configure Routes", err.Error()))
+		progress.Fail(err.Error())
 		return
 	}
 
 	//
-	util.LogDebug(stylish.Bullet("Running after deploy hooks..."))
+	progress.Step("after-deploy")
+	j.log().Debug(stylish.Bullet("Running after deploy hooks..."))
 
 	if err := j.RunDeployScripts("after", *box); err != nil {
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
-	util.UpdateStatus(j, "complete")
+	progress.Finish("complete")
 }
 
 func (j *Deploy) RemoveOldContainers() error {
@@ -281,7 +319,7 @@ func (j *Deploy) RemoveOldContainers() error {
 	for _, container := range containers {
 		util.RemoveForward(container.NetworkSettings.IPAddress)
 		if err := docker.RemoveContainer(container.ID); err != nil {
-			util.HandleError(stylish.Error("Failed to remove old containers", err.Error()))
+			j.log().HandleError(stylish.Error("Failed to remove old containers", err.Error()))
 			return err
 		}
 	}
@@ -291,15 +329,15 @@ func (j *Deploy) RemoveOldContainers() error {
 func (j *Deploy) SetupFS() error {
 	// Make sure we have the directories
 	if err := fs.CreateDirs(); err != nil {
-		util.HandleError(stylish.Error("Failed to create dirs", err.Error()))
+		j.log().HandleError(stylish.Error("Failed to create dirs", err.Error()))
 		return err
 	}
 
 	// wipe the previous deploy data if reset == true
 	if j.Reset {
-		util.LogInfo(stylish.Bullet("Emptying cache"))
+		j.log().Info(stylish.Bullet("Emptying cache"))
 		if err := fs.Clean(); err != nil {
-			util.HandleError(stylish.Warning("Failed to reset cache and code directories:\n%v", err.Error()))
+			j.log().HandleError(stylish.Warning("Failed to reset cache and code directories:\n%v", err.Error()))
 			return err
 		}
 	}
@@ -315,18 +353,18 @@ func (j *Deploy) CreateBuildContainer(box boxfile.Boxfile) error {
 
 	// if the build image doesn't exist it needs to be downloaded
 	if !docker.ImageExists(image) {
-		util.LogInfo(stylish.Bullet("Pulling the latest build image (this may take awhile)... "))
+		j.log().Info(stylish.Bullet("Pulling the latest build image (this may take awhile)... "))
 		docker.InstallImage(image)
 	}
 
-	util.LogDebug(stylish.Bullet("image name: %v", image))
+	j.log().Debug(stylish.Bullet("image name: %v", image))
 
 	// create a build container
-	util.LogInfo(stylish.Bullet("Creating build container"))
+	j.log().Info(stylish.Bullet("Creating build container"))
 
 	_, err := docker.CreateContainer(docker.CreateConfig{Image: image, Category: "build", UID: "build1"})
 	if err != nil {
-		util.HandleError(stylish.Error("Failed to create build container", err.Error()))
+		j.log().HandleError(stylish.Error("Failed to create build container", err.Error()))
 		return err
 	}
 	return nil
@@ -334,23 +372,23 @@ func (j *Deploy) CreateBuildContainer(box boxfile.Boxfile) error {
 
 func (j *Deploy) SetupBuild() error {
 	// run the default-user hook to get ssh keys setup
-	if _, err := script.Exec("default-user", "build1", fs.UserPayload()); err != nil {
+	if _, err := script.Exec(j.ID, "default-user", "build1", fs.UserPayload()); err != nil {
 		return err
 	}
 
-	if _, err := script.Exec("default-configure", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-configure", "build1", j.payload); err != nil {
 		return err
 	}
 
-	if _, err := script.Exec("default-detect", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-detect", "build1", j.payload); err != nil {
 		return err
 	}
 
-	if _, err := script.Exec("default-sync", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-sync", "build1", j.payload); err != nil {
 		return err
 	}
 
-	if _, err := script.Exec("default-setup", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-setup", "build1", j.payload); err != nil {
 		return err
 	}
 	return nil
@@ -358,22 +396,22 @@ func (j *Deploy) SetupBuild() error {
 
 func (j *Deploy) RunBuild() error {
 	// run prepare script (blocking)
-	if _, err := script.Exec("default-prepare", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-prepare", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run build script (blocking)
-	if _, err := script.Exec("default-build", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-build", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run publish script (blocking)
-	if _, err := script.Exec("default-publish", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-publish", "build1", j.payload); err != nil {
 		return err
 	}
 
 	// run cleanup script (blocking)
-	if _, err := script.Exec("default-cleanup", "build1", j.payload); err != nil {
+	if _, err := script.Exec(j.ID, "default-cleanup", "build1", j.payload); err != nil {
 		return err
 	}
 	return nil
@@ -387,7 +425,7 @@ func (j *Deploy) RunDeployScripts(stage string, box boxfile.Boxfile) error {
 		if bd != nil || bda != nil {
 
 			// run before deploy script (blocking)
-			if _, err := script.Exec(fmt.Sprintf("default-%s_deploy", stage), node, map[string]interface{}{stage + "_deploy": bd, stage + "_deploy_all": bda}); err != nil {
+			if _, err := script.Exec(j.ID, fmt.Sprintf("default-%s_deploy", stage), node, map[string]interface{}{stage + "_deploy": bd, stage + "_deploy_all": bda}); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,225 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package jobs
+
+import (
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nanobox-io/nanobox-boxfile"
+	"github.com/nanobox-io/nanobox-golang-stylish"
+	"github.com/nanobox-io/nanobox-router"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
+	"github.com/nanobox-io/nanobox-server/util/docker"
+	"github.com/nanobox-io/nanobox-server/util/events"
+	"github.com/nanobox-io/nanobox-server/util/health"
+	"github.com/nanobox-io/nanobox-server/util/pages"
+	"github.com/nanobox-io/nanobox-server/util/proxy"
+)
+
+// RouteHealth is a route with the health of each of its targets
+type RouteHealth struct {
+	ID        string          `json:"id"`
+	SubDomain string          `json:"subdomain,omitempty"`
+	Domain    string          `json:"domain,omitempty"`
+	Path      string          `json:"path"`
+	Targets   []health.Status `json:"targets"`
+}
+
+// NoHealthyTargets is the router error page for when the app has targets but
+// none of them pass their health checks
+type NoHealthyTargets struct{}
+
+// healthChecker checks every target the routes send requests to
+var healthChecker = health.New(nil)
+
+// unavailable serves the NoHealthyTargets page for single routes that lost all
+// of their targets while the rest of the app is still up
+var unavailable net.Listener
+
+var unavailableTex = sync.Mutex{}
+
+func init() {
+	healthChecker.OnChange = healthChanged
+}
+
+// healthCheck reads the health_check option of a web node. It is either the
+// path to check or a map with any of the path, interval, timeout, rise and
+// fall keys.
+func healthCheck(box boxfile.Boxfile) health.Check {
+	check := health.Check{}
+
+	if path := box.StringValue("health_check"); path != "" {
+		check.Path = path
+		return check
+	}
+
+	hc := box.Node("health_check")
+	if !hc.Valid {
+		return check
+	}
+	check.Path = hc.StringValue("path")
+	check.Interval, _ = time.ParseDuration(hc.StringValue("interval"))
+	check.Timeout, _ = time.ParseDuration(hc.StringValue("timeout"))
+	check.Rise = hc.IntValue("rise")
+	check.Fall = hc.IntValue("fall")
+	return check
+}
+
+// healthChanged republishes the routes when a target is ejected or restored
+// and lets the container's listeners know
+func healthChanged(status health.Status) {
+	routesTex.Lock()
+	publishRoutes()
+	routesTex.Unlock()
+
+	if uid := targetUID(status.Target); uid != "" {
+		events.Health(uid, status.Target, status.Healthy, status.Error)
+	}
+}
+
+// targetUID finds the code container a target points at
+func targetUID(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return ""
+	}
+
+	containers, _ := docker.ListContainers("code")
+	for _, container := range containers {
+		if container.NetworkSettings.IPAddress == host {
+			return container.Config.Labels["uid"]
+		}
+	}
+	return ""
+}
+
+// RoutesHealth lists the current routes and how their targets are doing
+func RoutesHealth() []RouteHealth {
+	routesTex.Lock()
+	defer routesTex.Unlock()
+
+	rtn := []RouteHealth{}
+	for _, group := range activeGroups {
+		routeHealth := RouteHealth{
+			ID:        group.ID,
+			SubDomain: group.SubDomain,
+			Domain:    group.Domain,
+			Path:      group.Path,
+			Targets:   []health.Status{},
+		}
+		for _, target := range group.targets {
+			routeHealth.Targets = append(routeHealth.Targets, healthChecker.Status(target.URL))
+		}
+		rtn = append(rtn, routeHealth)
+	}
+	return rtn
+}
+
+// setRouteErrorHandler shows the NoHealthyTargets page when the whole app is
+// down, or the app's no route page when there are no routes at all, and takes
+// them away again once the routes recover. Deploy pages are left alone. It
+// expects the caller to hold the routesTex lock.
+func setRouteErrorHandler(noRoutes, down bool) {
+	switch handler := router.ErrorHandler.(type) {
+	case nil, NoHealthyTargets:
+	case pages.Page:
+		if handler.Name != pages.NoRoute {
+			return
+		}
+	default:
+		return
+	}
+
+	_, showing := router.ErrorHandler.(NoHealthyTargets)
+
+	switch {
+	case down:
+		if !showing {
+			util.LogWarn(stylish.Warning("None of the app's containers are passing their health checks"))
+		}
+		router.ErrorHandler = NoHealthyTargets{}
+	case noRoutes:
+		router.ErrorHandler = pages.Page{Name: pages.NoRoute}
+	default:
+		router.ErrorHandler = nil
+	}
+}
+
+// unavailableURL starts the unavailable page server the first time it is
+// needed and returns its address
+func unavailableURL() (string, error) {
+	unavailableTex.Lock()
+	defer unavailableTex.Unlock()
+
+	if unavailable == nil {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", err
+		}
+		go http.Serve(listener, NoHealthyTargets{})
+		unavailable = listener
+	}
+	return "http://" + unavailable.Addr().String(), nil
+}
+
+// ServeHTTP
+func (n NoHealthyTargets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	proxy.ServePage("no-healthy-targets", n.serve, rw, req)
+}
+
+// serve lists the failing targets
+func (NoHealthyTargets) serve(rw http.ResponseWriter, req *http.Request) {
+	failing := []health.Status{}
+	for _, route := range RoutesHealth() {
+		for _, status := range route.Targets {
+			if !status.Healthy {
+				failing = append(failing, status)
+			}
+		}
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.Header().Set("Retry-After", fmt.Sprintf("%d", int(health.DefaultCheck.Interval.Seconds())))
+	rw.WriteHeader(http.StatusServiceUnavailable)
+
+	err := unhealthyPage.Execute(rw, map[string]interface{}{
+		"App":     config.App(),
+		"Host":    req.Host,
+		"Failing": failing,
+	})
+	if err != nil {
+		config.Log.Error("[nanobox/jobs] Unable to render the unhealthy page: %s", err.Error())
+	}
+}
+
+var unhealthyPage = template.Must(template.New("unhealthy").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.App}} is not responding</title></head>
+<body style="font-family: sans-serif; margin: 40px;">
+<h1>{{.App}} is not responding</h1>
+<p>The router has nowhere to send requests for {{.Host}}: none of the app's containers are passing their health checks.</p>
+<p>Check the app logs for a crash or a slow start. Containers go back into rotation as soon as they pass a check, this page will retry on its own.</p>
+{{if .Failing}}<table cellpadding="4">
+<tr><th align="left">target</th><th align="left">last checked</th><th align="left">error</th></tr>
+{{range .Failing}}<tr><td>{{.Target}}</td><td>{{.Checked.Format "15:04:05"}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>{{end}}
+<script>setTimeout(function () { location.reload() }, 5000)</script>
+</body>
+</html>
+`))
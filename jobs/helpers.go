@@ -7,11 +7,13 @@ import (
 	"strings"
 
 	"github.com/nanobox-io/nanobox-boxfile"
+	"github.com/nanobox-io/nanobox-golang-stylish"
 	"github.com/nanobox-io/nanobox-router"
 
 	"github.com/nanobox-io/nanobox-server/config"
 	"github.com/nanobox-io/nanobox-server/util"
 	"github.com/nanobox-io/nanobox-server/util/docker"
+	"github.com/nanobox-io/nanobox-server/util/pages"
 	"github.com/nanobox-io/nanobox-server/util/script"
 )
 
@@ -30,49 +32,24 @@ func init() {
 // grab the original boxfile and loop through the webs
 // find all routes and regsiter the routes with the router
 func configureRoutes(box boxfile.Boxfile) error {
-	newRoutes := []router.Route{}
-	webs := box.Nodes("web")
-	for _, web := range webs {
-		b := box.Node(web)
-		container, err := docker.GetContainer(web)
-		if err != nil {
-			// if the container doesnt exist just continue and dont
-			// add routes for that node
-			continue
-		}
+	// clear the deploy page first so the routes can put up the unhealthy page
+	router.ErrorHandler = nil
+	return applyRoutes(box)
+}
 
-		ip := container.NetworkSettings.IPAddress
-		for _, route := range routes(b) {
-			config.Log.Debug("web:ports: %+v\n", ports(b))
-			for _, to := range ports(b)["http"] {
-				route.Targets = append(route.Targets, "http://"+ip+":"+to)
-			}
-			newRoutes = append(newRoutes, route)
-		}
+// loadPages loads the app's router pages from the Boxfile pages node and the
+// app dir
+func loadPages(box boxfile.Boxfile) {
+	node := box.Node("pages")
+	values := map[string]string{}
+	for _, name := range pages.Names {
+		values[name] = node.StringValue(name)
+		values[name+"_html"] = node.StringValue(name + "_html")
 	}
 
-	// add the default route if we dont have one
-	defaulted := false
-	for _, route := range newRoutes {
-		if route.Domain == "" && route.Path == "/" {
-			defaulted = true
-			break
-		}
+	if err := pages.Load(config.MountFolder+"code/"+config.App(), values); err != nil {
+		util.LogWarn(stylish.Warning("Unable to load the custom pages: %s", err.Error()))
 	}
-	if !defaulted {
-		if web1, err := docker.GetContainer("web1"); err == nil {
-			ip := web1.NetworkSettings.IPAddress
-			route := router.Route{Path: "/"}
-			b := box.Node("web1")
-			for _, to := range ports(b)["http"] {
-				route.Targets = append(route.Targets, "http://"+ip+":"+to)
-			}
-			newRoutes = append(newRoutes, route)
-		}
-	}
-	router.UpdateRoutes(newRoutes)
-	router.ErrorHandler = nil
-	return nil
 }
 
 func clearPorts() {
@@ -81,15 +58,22 @@ func clearPorts() {
 		return
 	}
 
-	// remove all old forwards
+	// find the old forwards first, removing them changes the vips
+	hosts := []string{}
 	for _, vip := range vips {
 		// leave in our reserved router ports
-		if vip.Port != 80 && vip.Port != 443 {
-			for _, server := range vip.Servers {
-				util.RemoveForward(server.Host)
-			}
+		if vip.Type == "tcp" && (vip.Port == 80 || vip.Port == 443) {
+			continue
+		}
+		for _, server := range vip.Servers {
+			hosts = append(hosts, server.Host)
 		}
 	}
+
+	// remove all old tcp and udp forwards
+	for _, host := range hosts {
+		util.RemoveForward(host)
+	}
 }
 
 func configurePorts(box boxfile.Boxfile) error {
@@ -107,19 +91,20 @@ func configurePorts(box boxfile.Boxfile) error {
 		}
 		ip := container.NetworkSettings.IPAddress
 		for pType, ports := range ports(b) {
-			// TEMPORARY if conditional
-			// can remove once https://github.com/nanobox-io/golang-lvs/issues/3
-			// has been solved
-			if pType == "http" || pType == "tcp" {
-				for from, to := range ports {
-					// dont over write our reserved router
-					// ports
-					if from != "443" && from != "80" {
-						err := util.AddForward(from, ip, to)
-						if err != nil {
-							config.Log.Debug("failed to add forward %+v", err)
-						}
-					}
+			// http ports are forwarded over tcp
+			netType := "tcp"
+			if pType == "udp" {
+				netType = "udp"
+			}
+			for from, to := range ports {
+				// dont over write our reserved router
+				// ports
+				if netType == "tcp" && (from == "443" || from == "80") {
+					continue
+				}
+				err := util.AddForward(netType, from, ip, to)
+				if err != nil {
+					config.Log.Debug("failed to add forward %+v", err)
 				}
 			}
 		}
@@ -127,34 +112,6 @@ func configurePorts(box boxfile.Boxfile) error {
 	return nil
 }
 
-func routes(box boxfile.Boxfile) (rtn []router.Route) {
-	boxRoutes, ok := box.Value("routes").([]string)
-	if !ok {
-		tmps, ok := box.Value("routes").([]interface{})
-		if !ok {
-			return
-		}
-		for _, tmp := range tmps {
-			if str, ok := tmp.(string); ok {
-				boxRoutes = append(boxRoutes, str)
-			}
-		}
-	}
-	for _, route := range boxRoutes {
-		routeParts := strings.Split(route, ":")
-		switch len(routeParts) {
-		case 1:
-			rtn = append(rtn, router.Route{Path: routeParts[0]})
-		case 2:
-			subDomain := strings.Trim(routeParts[0], ".")
-			rtn = append(rtn, router.Route{SubDomain: subDomain, Path: routeParts[0]})
-		}
-
-	}
-
-	return
-}
-
 func ports(box boxfile.Boxfile) map[string]map[string]string {
 	rtn := map[string]map[string]string{
 		"http": map[string]string{},
@@ -230,7 +187,7 @@ func EngineBoxfile(refresh bool) *boxfile.Boxfile {
 			"boxfile":     UserBoxfile(false).Node("build").Parsed,
 			"logtap_host": config.LogtapHost,
 		}
-		if out, err := script.Exec("default-boxfile", "build1", pload); err == nil {
+		if out, err := script.Exec("", "default-boxfile", "build1", pload); err == nil {
 			box := boxfile.New([]byte(out))
 			engineBoxfile = &box
 		}
@@ -279,23 +236,42 @@ func DefaultEVars(box boxfile.Boxfile) map[string]string {
 	return evar
 }
 
-func SetLibDirs() {
-	dockerLibDirs := []string{}
+// DevBoxfile returns the boxfile node for a named dev environment. A dev
+// environment named "api" is configured by a "dev-api" node and falls back to
+// the plain "dev" node when there isnt one.
+func DevBoxfile(name string) boxfile.Boxfile {
 	box := CombinedBoxfile(false)
-	libDirs, ok := box.Node("build").Value("lib_dirs").([]interface{})
-	if ok && !box.Node("dev").BoolValue("ignore_lib_dirs") {
-		for _, libDir := range libDirs {
-			strDir, ok := libDir.(string)
-			if ok && isDir("/mnt/sda/var/nanobox/cache/lib_dirs/"+strDir) {
-				dockerLibDirs = append(dockerLibDirs, fmt.Sprintf("/mnt/sda/var/nanobox/cache/lib_dirs/%s/:/code/%s/", strDir, strDir))
-			}
+	if name != "" && box.Node("dev-"+name).Valid {
+		return box.Node("dev-" + name)
+	}
+	return box.Node("dev")
+}
+
+// DevLibDirs builds the lib dir binds for a dev environment. The environment
+// can list its own lib_dirs, otherwise the ones from the build are used.
+func DevLibDirs(name string) []string {
+	dockerLibDirs := []string{}
+	dev := DevBoxfile(name)
+	if dev.BoolValue("ignore_lib_dirs") {
+		return dockerLibDirs
+	}
+
+	libDirs, ok := dev.Value("lib_dirs").([]interface{})
+	if !ok {
+		libDirs, _ = CombinedBoxfile(false).Node("build").Value("lib_dirs").([]interface{})
+	}
+	for _, libDir := range libDirs {
+		strDir, ok := libDir.(string)
+		if ok && isDir("/mnt/sda/var/nanobox/cache/lib_dirs/"+strDir) {
+			dockerLibDirs = append(dockerLibDirs, fmt.Sprintf("/mnt/sda/var/nanobox/cache/lib_dirs/%s/:/code/%s/", strDir, strDir))
 		}
 	}
-	docker.LibDirs = dockerLibDirs
+	return dockerLibDirs
 }
 
-func SetWorkingDir() {
-	docker.WorkingDir = CombinedBoxfile(false).Node("dev").StringValue("working_dir")
+// DevWorkingDir
+func DevWorkingDir(name string) string {
+	return DevBoxfile(name).StringValue("working_dir")
 }
 
 func isDir(path string) bool {
@@ -14,16 +14,21 @@ import (
 	"github.com/nanobox-io/nanobox-server/util/docker"
 )
 
+// imageUpdateSteps are the stages an image update reports its progress in
+var imageUpdateSteps = []string{"list-images", "pull-images"}
+
 type ImageUpdate struct{}
 
 //
 func (j *ImageUpdate) Process() {
+	progress := util.NewProgress("ImageUpdate", "", imageUpdateSteps...)
 
 	//
+	progress.Step("list-images")
 	images, err := docker.ListImages()
 	if err != nil {
 		util.HandleError("Unable to pull images:" + err.Error())
-		util.UpdateStatus(j, "errored")
+		progress.Fail(err.Error())
 		return
 	}
 
@@ -33,6 +38,7 @@ func (j *ImageUpdate) Process() {
 	}
 
 	//
+	progress.Step("pull-images")
 	for _, image := range images {
 		for _, tag := range image.RepoTags {
 
@@ -41,7 +47,7 @@ func (j *ImageUpdate) Process() {
 				util.LogInfo(stylish.SubBullet("- Updating image: %s", tag))
 				if err := docker.InstallImage(tag); err != nil {
 					util.HandleError("Unable to update image:" + err.Error())
-					util.UpdateStatus(j, "errored")
+					progress.Fail(err.Error())
 					return
 				}
 			}
@@ -49,5 +55,5 @@ func (j *ImageUpdate) Process() {
 	}
 
 	util.LogInfo(stylish.SubBullet("- Update complete"))
-	util.UpdateStatus(j, "complete")
+	progress.Finish("complete")
 }
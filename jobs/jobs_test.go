@@ -1,6 +1,7 @@
 package jobs_test
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
@@ -75,7 +76,7 @@ func TestSetupBuild(t *testing.T) {
 	mFs.EXPECT().UserPayload()
 
 	names := []string{}
-	script.Exec = func(name, container string, payload map[string]interface{}) ([]byte, error) {
+	script.Exec = func(job, name, container string, payload map[string]interface{}) ([]byte, error) {
 		names = append(names, name)
 		return []byte{}, nil
 	}
@@ -101,7 +102,7 @@ func TestSetupBuild(t *testing.T) {
 
 func TestRunBuild(t *testing.T) {
 	names := []string{}
-	script.Exec = func(name, container string, payload map[string]interface{}) ([]byte, error) {
+	script.Exec = func(job, name, container string, payload map[string]interface{}) ([]byte, error) {
 		names = append(names, name)
 		return []byte{}, nil
 	}
@@ -126,7 +127,7 @@ func TestRunBuild(t *testing.T) {
 
 func TestRunDeployScripts(t *testing.T) {
 	names := []string{}
-	script.Exec = func(name, container string, payload map[string]interface{}) ([]byte, error) {
+	script.Exec = func(job, name, container string, payload map[string]interface{}) ([]byte, error) {
 		names = append(names, name)
 		return []byte{}, nil
 	}
@@ -165,3 +166,57 @@ build:
 		t.Error("Numeric ports are not being processed correctly")
 	}
 }
+
+func TestParseRoute(t *testing.T) {
+	valid := map[string]jobs.Route{
+		"/":                 jobs.Route{Path: "/"},
+		"/admin":            jobs.Route{Path: "/admin"},
+		"admin":             jobs.Route{SubDomain: "admin", Path: "/"},
+		"admin:/":           jobs.Route{SubDomain: "admin", Path: "/"},
+		"admin:":            jobs.Route{SubDomain: "admin", Path: "/"},
+		"api.v2.:/":         jobs.Route{SubDomain: "api.v2", Path: "/"},
+		"example.com:/blog": jobs.Route{Domain: "example.com", Path: "/blog"},
+		":/api:8080":        jobs.Route{Path: "/api", Port: "8080"},
+	}
+	for str, expected := range valid {
+		route, err := jobs.ParseRoute(str)
+		if err != nil {
+			t.Errorf("%s should be valid: %s", str, err.Error())
+			continue
+		}
+		if !reflect.DeepEqual(route, expected) {
+			t.Errorf("%s parsed into %+v but I expected %+v", str, route, expected)
+		}
+	}
+
+	route, err := jobs.ParseRoute(map[interface{}]interface{}{"subdomain": "admin", "path": "/api", "strip_prefix": true, "port": 8080})
+	expected := jobs.Route{SubDomain: "admin", Path: "/api", StripPrefix: true, Port: "8080"}
+	if err != nil || !reflect.DeepEqual(route, expected) {
+		t.Errorf("map route parsed into %+v (%v) but I expected %+v", route, err, expected)
+	}
+
+	route, err = jobs.ParseRoute(map[interface{}]interface{}{"path": "/", "nodes": []interface{}{"web1", "web2"}, "weights": map[interface{}]interface{}{"web1": 3, "web2": 1}, "sticky": true})
+	expected = jobs.Route{Path: "/", Nodes: []string{"web1", "web2"}, Weights: map[string]int{"web1": 3, "web2": 1}, Sticky: "nanobox_sticky"}
+	if err != nil || !reflect.DeepEqual(route, expected) {
+		t.Errorf("balanced route parsed into %+v (%v) but I expected %+v", route, err, expected)
+	}
+
+	invalid := []interface{}{
+		"admin:api",
+		"Admin!:/",
+		":/:http",
+		":/:70000",
+		"a:b:c:d",
+		map[string]interface{}{"subdomain": "admin", "domain": "example.com"},
+		map[string]interface{}{"strip_prefix": true},
+		map[string]interface{}{"path": "/", "wieght": 2},
+		map[string]interface{}{"path": "/", "nodes": []interface{}{"web1"}, "weights": map[string]interface{}{"web2": 2}},
+		map[string]interface{}{"path": "/", "weights": map[string]interface{}{"web1": 0}},
+		42,
+	}
+	for _, value := range invalid {
+		if _, err := jobs.ParseRoute(value); err == nil {
+			t.Errorf("%v should not be a valid route", value)
+		}
+	}
+}
@@ -20,17 +20,22 @@ type Restart struct {
 	UID     string
 	Success bool
 	Boxfile boxfile.Boxfile
+
+	// Job is the id of the build doing the restart
+	Job string
 }
 
 // Proccess syncronies your docker containers with the boxfile specification
 func (j *Restart) Process() {
+	log := util.JobLog{ID: j.Job}
+
 	// add a lock so the service wont go down whil im running
 	util.Lock()
 	defer util.Unlock()
 
 	j.Success = false
 
-	util.LogInfo(stylish.Bullet("Restarting app in %s container...", j.UID))
+	log.Info(stylish.Bullet("Restarting app in %s container...", j.UID))
 	box := CombinedBoxfile(false)
 	// restart payload
 	payload := map[string]interface{}{
@@ -41,8 +46,8 @@ func (j *Restart) Process() {
 	}
 
 	// run restart hook (blocking)
-	if _, err := script.Exec("default-restart", j.UID, payload); err != nil {
-		util.LogInfo("ERROR %v\n", err)
+	if _, err := script.Exec(j.Job, "default-restart", j.UID, payload); err != nil {
+		log.Info("ERROR %v\n", err)
 		return
 	}
 
@@ -0,0 +1,577 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package jobs
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nanobox-io/nanobox-boxfile"
+	"github.com/nanobox-io/nanobox-golang-stylish"
+	"github.com/nanobox-io/nanobox-router"
+	"github.com/pborman/uuid"
+
+	"github.com/nanobox-io/nanobox-server/util"
+	"github.com/nanobox-io/nanobox-server/util/docker"
+	"github.com/nanobox-io/nanobox-server/util/health"
+	"github.com/nanobox-io/nanobox-server/util/proxy"
+)
+
+// Route is a route as it is described in the Boxfile. Routes are given either
+// as a string:
+//
+//   [subdomain|domain]:[path][:port]
+//
+// (a host containing a dot is a domain unless it ends in one, eg "api.v2.")
+// or as a map with any of the subdomain, domain, path, strip_prefix and port
+// keys. The map form also controls how requests are balanced:
+//
+//   nodes:   the web nodes that share the route (defaults to the node it is on)
+//   weights: a weight per web node, eg {web1: 3, web2: 1}
+//   sticky:  true (or a cookie name) to keep a session on the same target
+type Route struct {
+	ID          string
+	SubDomain   string
+	Domain      string
+	Path        string
+	StripPrefix bool
+	Port        string
+	Nodes       []string
+	Weights     map[string]int
+	Sticky      string
+}
+
+// the cookie used for sticky routes that dont name their own
+const stickyCookie = "nanobox_sticky"
+
+var hostRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+// ParseRoute parses and validates a single Boxfile route
+func ParseRoute(value interface{}) (Route, error) {
+	route := Route{}
+
+	switch v := value.(type) {
+	case string:
+		parts := strings.Split(v, ":")
+		switch len(parts) {
+		case 1:
+			// a lone path or a lone host
+			if strings.HasPrefix(parts[0], "/") {
+				route.Path = parts[0]
+			} else {
+				route.setHost(parts[0])
+			}
+		case 2:
+			route.setHost(parts[0])
+			route.Path = parts[1]
+		case 3:
+			route.setHost(parts[0])
+			route.Path = parts[1]
+			route.Port = parts[2]
+		default:
+			return route, fmt.Errorf("%q has too many parts", v)
+		}
+
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = val
+		}
+		return ParseRoute(m)
+
+	case map[string]interface{}:
+		for key, val := range v {
+			switch key {
+			case "subdomain":
+				route.SubDomain = fmt.Sprintf("%v", val)
+			case "domain":
+				route.Domain = fmt.Sprintf("%v", val)
+			case "path":
+				route.Path = fmt.Sprintf("%v", val)
+			case "port":
+				route.Port = fmt.Sprintf("%v", val)
+			case "strip_prefix":
+				strip, ok := val.(bool)
+				if !ok {
+					return route, fmt.Errorf("strip_prefix must be true or false")
+				}
+				route.StripPrefix = strip
+			case "nodes":
+				nodes, ok := val.([]interface{})
+				if !ok {
+					return route, fmt.Errorf("nodes must be a list of web nodes")
+				}
+				for _, node := range nodes {
+					route.Nodes = append(route.Nodes, fmt.Sprintf("%v", node))
+				}
+			case "weights":
+				weights, err := parseWeights(val)
+				if err != nil {
+					return route, err
+				}
+				route.Weights = weights
+			case "sticky":
+				switch sticky := val.(type) {
+				case bool:
+					if sticky {
+						route.Sticky = stickyCookie
+					}
+				case string:
+					route.Sticky = sticky
+				default:
+					return route, fmt.Errorf("sticky must be true, false or a cookie name")
+				}
+			default:
+				return route, fmt.Errorf("unknown route option %q", key)
+			}
+		}
+
+	default:
+		return route, fmt.Errorf("%v is not a valid route", value)
+	}
+
+	if route.Path == "" {
+		route.Path = "/"
+	}
+
+	return route, route.validate()
+}
+
+// parseWeights
+func parseWeights(val interface{}) (map[string]int, error) {
+	weights := map[string]int{}
+
+	m := map[string]interface{}{}
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		for key, weight := range v {
+			m[fmt.Sprintf("%v", key)] = weight
+		}
+	case map[string]interface{}:
+		m = v
+	default:
+		return nil, fmt.Errorf("weights must be a map of web nodes to weights")
+	}
+
+	for node, weight := range m {
+		w, ok := weight.(int)
+		if !ok || w < 1 {
+			return nil, fmt.Errorf("the weight for %s must be a number above 0", node)
+		}
+		weights[node] = w
+	}
+	return weights, nil
+}
+
+// setHost decides if a host is a subdomain or a full domain
+func (r *Route) setHost(host string) {
+	switch {
+	case strings.HasSuffix(host, "."):
+		r.SubDomain = strings.Trim(host, ".")
+	case strings.Contains(host, "."):
+		r.Domain = host
+	default:
+		r.SubDomain = host
+	}
+}
+
+// validate
+func (r Route) validate() error {
+	if r.SubDomain != "" && r.Domain != "" {
+		return fmt.Errorf("a route can have a subdomain or a domain but not both")
+	}
+	if r.SubDomain != "" && !hostRegex.MatchString(r.SubDomain) {
+		return fmt.Errorf("%q is not a valid subdomain", r.SubDomain)
+	}
+	if r.Domain != "" && (!hostRegex.MatchString(r.Domain) || !strings.Contains(r.Domain, ".")) {
+		return fmt.Errorf("%q is not a valid domain", r.Domain)
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("the path %q must start with a /", r.Path)
+	}
+	if r.StripPrefix && r.Path == "/" {
+		return fmt.Errorf("there is no prefix to strip from the path /")
+	}
+	if r.Port != "" {
+		port, err := strconv.Atoi(r.Port)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%q is not a valid port", r.Port)
+		}
+	}
+	for node := range r.Weights {
+		if len(r.Nodes) > 0 && !contains(r.Nodes, node) {
+			return fmt.Errorf("there is a weight for %s but it isnt one of the route's nodes", node)
+		}
+	}
+	return nil
+}
+
+// key identifies the requests a route matches, routes with the same key
+// share a single router route
+func (r Route) key() string {
+	return r.SubDomain + "|" + r.Domain + "|" + r.Path
+}
+
+// routes parses every route on a boxfile node. The routes that are valid are
+// returned along with an error for each one that isnt.
+func routes(node string, box boxfile.Boxfile) (rtn []Route, errs []error) {
+	boxRoutes, ok := box.Value("routes").([]interface{})
+	if !ok {
+		strs, ok := box.Value("routes").([]string)
+		if !ok {
+			return
+		}
+		for _, str := range strs {
+			boxRoutes = append(boxRoutes, str)
+		}
+	}
+
+	for i, boxRoute := range boxRoutes {
+		route, err := ParseRoute(boxRoute)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("route %d: %s", i+1, err.Error()))
+			continue
+		}
+		route.ID = fmt.Sprintf("%s-%d", node, i)
+		rtn = append(rtn, route)
+	}
+
+	return
+}
+
+// AdhocRoute is a temporary route added through the api. It is kept when the
+// routes are rebuilt from the Boxfile until it is removed again.
+type AdhocRoute struct {
+	Route
+
+	// requests go to a port on a container or to a host:port address
+	Container string
+	Host      string
+}
+
+var adhocRoutes = map[string]AdhocRoute{}
+
+var routesTex = sync.Mutex{}
+
+// AddAdhocRoute validates and registers a route and reconfigures the router
+func AddAdhocRoute(route AdhocRoute) (AdhocRoute, error) {
+	if route.Path == "" {
+		route.Path = "/"
+	}
+	if err := route.validate(); err != nil {
+		return route, err
+	}
+
+	switch {
+	case route.Container != "" && route.Host != "":
+		return route, fmt.Errorf("a route can go to a container or a host but not both")
+	case route.Container != "" && route.Port == "":
+		return route, fmt.Errorf("a port is needed to route to a container")
+	case route.Host != "":
+		if _, _, err := net.SplitHostPort(route.Host); err != nil {
+			return route, fmt.Errorf("%q is not a host:port address", route.Host)
+		}
+		if route.Port != "" {
+			return route, fmt.Errorf("the port belongs in the host address")
+		}
+	case route.Container == "":
+		return route, fmt.Errorf("a route needs a container or a host")
+	}
+
+	route.ID = "adhoc-" + strings.Split(uuid.New(), "-")[0]
+
+	routesTex.Lock()
+	adhocRoutes[route.ID] = route
+	routesTex.Unlock()
+
+	// problems with the Boxfile routes are reported in the deploy logs and
+	// shouldnt fail the adhoc route
+	RefreshRoutes()
+	return route, nil
+}
+
+// RemoveAdhocRoute
+func RemoveAdhocRoute(id string) error {
+	routesTex.Lock()
+	if _, ok := adhocRoutes[id]; !ok {
+		routesTex.Unlock()
+		return fmt.Errorf("there is no route %s", id)
+	}
+	delete(adhocRoutes, id)
+	routesTex.Unlock()
+
+	RefreshRoutes()
+	return nil
+}
+
+// AdhocRoutes
+func AdhocRoutes() []AdhocRoute {
+	routesTex.Lock()
+	defer routesTex.Unlock()
+
+	rtn := []AdhocRoute{}
+	for _, route := range adhocRoutes {
+		rtn = append(rtn, route)
+	}
+	return rtn
+}
+
+// RefreshRoutes rebuilds the routes from the cached Boxfile
+func RefreshRoutes() error {
+	return applyRoutes(*CombinedBoxfile(false))
+}
+
+// adhocTargets finds where an adhoc route should send its requests
+func adhocTargets(route AdhocRoute) ([]proxy.Target, error) {
+	if route.Host != "" {
+		return []proxy.Target{{URL: "http://" + route.Host, Weight: 1}}, nil
+	}
+
+	container, err := docker.GetContainer(route.Container)
+	if err != nil {
+		return nil, err
+	}
+	return []proxy.Target{{URL: "http://" + container.NetworkSettings.IPAddress + ":" + route.Port, Weight: 1}}, nil
+}
+
+// routeGroup is everything that ends up behind a single router route
+type routeGroup struct {
+	Route
+	targets []proxy.Target
+}
+
+// activeGroups are the routes as they were last applied, they are published
+// again whenever the health of a target changes
+var activeGroups = []*routeGroup{}
+
+// addTargets adds the targets the group doesnt have yet
+func (g *routeGroup) addTargets(targets []proxy.Target) {
+	for _, target := range targets {
+		found := false
+		for _, existing := range g.targets {
+			if existing.URL == target.URL {
+				found = true
+				break
+			}
+		}
+		if !found {
+			g.targets = append(g.targets, target)
+		}
+	}
+}
+
+// healthyTargets
+func (g *routeGroup) healthyTargets() []proxy.Target {
+	healthy := []proxy.Target{}
+	for _, target := range g.targets {
+		if healthChecker.Healthy(target.URL) {
+			healthy = append(healthy, target)
+		}
+	}
+	return healthy
+}
+
+// routerRoute turns the group into a route for the router that only sends
+// requests to the given targets. The router hands the requests to the route's
+// proxy, which balances them, rewrites them and writes the access log.
+func (g *routeGroup) routerRoute(targets []proxy.Target) (router.Route, error) {
+	newRoute := router.Route{
+		SubDomain: g.SubDomain,
+		Domain:    g.Domain,
+		Path:      g.Path,
+	}
+
+	stripPrefix := ""
+	if g.StripPrefix {
+		stripPrefix = g.Path
+	}
+
+	p, err := proxy.Ensure(g.ID)
+	if err != nil {
+		return newRoute, err
+	}
+	if err := p.Update(targets, stripPrefix, g.Sticky); err != nil {
+		return newRoute, err
+	}
+	newRoute.Targets = []string{p.URL()}
+	return newRoute, nil
+}
+
+// applyRoutes registers the Boxfile and adhoc routes with the router without
+// touching the error handler. Routes on different web nodes that match the
+// same requests are balanced across all of those nodes. Every target is health
+// checked and only gets requests while it passes.
+func applyRoutes(box boxfile.Boxfile) error {
+	routesTex.Lock()
+	defer routesTex.Unlock()
+
+	groups := []*routeGroup{}
+	byKey := map[string]*routeGroup{}
+	checked := []string{}
+	add := func(route Route, targets []proxy.Target, check health.Check) {
+		group, ok := byKey[route.key()]
+		if !ok {
+			group = &routeGroup{Route: route}
+			byKey[route.key()] = group
+			groups = append(groups, group)
+		}
+		group.StripPrefix = group.StripPrefix || route.StripPrefix
+		if group.Sticky == "" {
+			group.Sticky = route.Sticky
+		}
+		group.addTargets(targets)
+
+		for _, target := range targets {
+			healthChecker.Watch(target.URL, check)
+			checked = append(checked, target.URL)
+		}
+	}
+
+	invalid := false
+	webs := box.Nodes("web")
+	for _, web := range webs {
+		webRoutes, errs := routes(web, box.Node(web))
+		for _, err := range errs {
+			util.LogError(stylish.Warning("Invalid route on %s: %s", web, err.Error()))
+			invalid = true
+		}
+
+		for _, route := range webRoutes {
+			nodes := route.Nodes
+			if len(nodes) == 0 {
+				nodes = []string{web}
+			}
+
+			for _, node := range nodes {
+				if !contains(webs, node) {
+					util.LogError(stylish.Warning("Invalid route on %s: %s is not a web node", web, node))
+					invalid = true
+					continue
+				}
+
+				targets, err := webTargets(node, route, box)
+				if err != nil {
+					// if the container doesnt exist just continue and dont
+					// add routes for that node
+					continue
+				}
+				add(route, targets, healthCheck(box.Node(node)))
+			}
+		}
+	}
+
+	// keep the routes that were added through the api
+	for _, route := range adhocRoutes {
+		targets, err := adhocTargets(route)
+		if err != nil {
+			util.LogWarn(stylish.Warning("Unable to route %s: %s", route.ID, err.Error()))
+			continue
+		}
+		add(route.Route, targets, health.Check{})
+	}
+
+	// add the default route if we dont have one, it is shared by every web
+	defaultRoute := Route{ID: "default", Path: "/"}
+	if _, ok := byKey[defaultRoute.key()]; !ok {
+		for _, web := range webs {
+			if targets, err := webTargets(web, defaultRoute, box); err == nil {
+				add(defaultRoute, targets, healthCheck(box.Node(web)))
+			}
+		}
+	}
+
+	// stop checking targets that went away
+	healthChecker.Prune(checked)
+
+	activeGroups = groups
+	publishRoutes()
+
+	if invalid {
+		return fmt.Errorf("the Boxfile has invalid routes")
+	}
+	return nil
+}
+
+// publishRoutes hands the active routes to the router with their unhealthy
+// targets left out. A route that lost all of its targets shows the
+// NoHealthyTargets page. It expects the caller to hold the routesTex lock.
+func publishRoutes() {
+	newRoutes := []router.Route{}
+	proxies := []string{}
+	up, down := 0, 0
+	for _, group := range activeGroups {
+		targets := group.healthyTargets()
+
+		var newRoute router.Route
+		var err error
+		switch {
+		case len(group.targets) > 0 && len(targets) == 0:
+			down++
+			newRoute = router.Route{SubDomain: group.SubDomain, Domain: group.Domain, Path: group.Path}
+			var url string
+			url, err = unavailableURL()
+			newRoute.Targets = []string{url}
+		default:
+			if len(targets) > 0 {
+				up++
+			}
+			newRoute, err = group.routerRoute(targets)
+			proxies = append(proxies, group.ID)
+		}
+		if err != nil {
+			util.LogError(stylish.Warning("Unable to route %s: %s", group.ID, err.Error()))
+			continue
+		}
+		newRoutes = append(newRoutes, newRoute)
+	}
+
+	// stop the proxies of routes that went away
+	proxy.Prune(proxies)
+
+	router.UpdateRoutes(newRoutes)
+
+	setRouteErrorHandler(len(newRoutes) == 0, down > 0 && up == 0)
+}
+
+// webTargets returns where a route sends requests on a web node. A route with
+// a port only goes to that port, otherwise it goes to every http port.
+func webTargets(node string, route Route, box boxfile.Boxfile) ([]proxy.Target, error) {
+	container, err := docker.GetContainer(node)
+	if err != nil {
+		return nil, err
+	}
+	ip := container.NetworkSettings.IPAddress
+
+	weight := route.Weights[node]
+	if weight < 1 {
+		weight = 1
+	}
+
+	if route.Port != "" {
+		return []proxy.Target{{URL: "http://" + ip + ":" + route.Port, Weight: weight}}, nil
+	}
+
+	targets := []proxy.Target{}
+	for _, to := range ports(box.Node(node))["http"] {
+		targets = append(targets, proxy.Target{URL: "http://" + ip + ":" + to, Weight: weight})
+	}
+	return targets, nil
+}
+
+// contains
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if entry == item {
+			return true
+		}
+	}
+	return false
+}
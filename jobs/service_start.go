@@ -20,16 +20,19 @@ import (
 
 //
 type ServiceStart struct {
-	deploy Deploy
-
 	Boxfile boxfile.Boxfile
 	EVars   map[string]string
 	Success bool
 	UID     string
+
+	// Job is the id of the deploy starting the service
+	Job string
 }
 
 //
 func (j *ServiceStart) Process() {
+	log := util.JobLog{ID: j.Job}
+
 	// var ci *docker.Container
 	var err error
 
@@ -61,22 +64,24 @@ func (j *ServiceStart) Process() {
 	createConfig.Image = image
 
 	if !docker.ImageExists(createConfig.Image) {
-		util.LogInfo(stylish.SubBullet("- Pulling the %s image (this may take awhile)... ", createConfig.Image))
+		log.Info(stylish.SubBullet("- Pulling the %s image (this may take awhile)... ", createConfig.Image))
 		docker.InstallImage(createConfig.Image)
 	}
 
-	util.LogDebug(stylish.SubBullet("- Image name: %v", createConfig.Image))
+	log.Debug(stylish.SubBullet("- Image name: %v", createConfig.Image))
 
-	util.LogInfo(stylish.SubBullet("- Creating %v container", j.UID))
+	log.Info(stylish.SubBullet("- Creating %v container", j.UID))
 
 	// start the container
 	if _, err = docker.CreateContainer(createConfig); err != nil {
-		util.HandleError(stylish.ErrorHead("Failed to create %v container", j.UID))
-		util.HandleError(stylish.ErrorBody(err.Error()))
-		util.UpdateStatus(&j.deploy, "errored")
+		log.HandleError(stylish.ErrorHead("Failed to create %v container", j.UID))
+		log.HandleError(stylish.ErrorBody(err.Error()))
 		return
 	}
 
+	// send everything the container writes to logtap
+	util.CaptureLogs(j.UID, 0)
+
 	// payload
 	payload := map[string]interface{}{
 		"platform":    "local",
@@ -103,25 +108,21 @@ func (j *ServiceStart) Process() {
 	}
 
 	// run configure hook (blocking)
-	if data, err := script.Exec("default-configure", j.UID, payload); err != nil {
-		util.LogDebug("Failed Script Output:\n%s\n", data)
-		util.HandleError(stylish.Error("Configure hook failed", err.Error()))
-		util.UpdateStatus(&j.deploy, "errored")
+	if _, err := script.Exec(j.Job, "default-configure", j.UID, payload); err != nil {
+		log.HandleError(stylish.Error("Configure hook failed", err.Error()))
 		return
 	}
 
-	util.LogInfo(stylish.SubBullet("- Starting %v service", j.UID))
+	log.Info(stylish.SubBullet("- Starting %v service", j.UID))
 
 	// run start hook (blocking)
-	if data, err := script.Exec("default-start", j.UID, payload); err != nil {
-		util.LogDebug("Failed Script Output:\n%s\n", data)
-		util.HandleError(stylish.Error("Start hook failed", err.Error()))
-		util.UpdateStatus(&j.deploy, "errored")
+	if _, err := script.Exec(j.Job, "default-start", j.UID, payload); err != nil {
+		log.HandleError(stylish.Error("Start hook failed", err.Error()))
 		return
 	}
 
 	// if we make it to the end it was a success!
 	j.Success = true
 
-	util.LogDebug("   [√] SUCCESS\n")
+	log.Debug("   [√] SUCCESS\n")
 }
@@ -8,7 +8,10 @@ package jobs
 
 //
 import (
+	"time"
+
 	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
 	"github.com/nanobox-io/nanobox-server/util/docker"
 	"github.com/nanobox-io/nanobox-server/util/worker"
 )
@@ -23,6 +26,7 @@ func (j *Startup) Process() {
 	docker.RemoveContainer("exec1")
 	box := CombinedBoxfile(false)
 
+	loadPages(*UserBoxfile(false))
 	configureRoutes(*box)
 	configurePorts(*box)
 
@@ -38,5 +42,11 @@ func (j *Startup) Process() {
 		worker.Queue(&s)
 	}
 
+	// pick the container output back up from where we are now
+	codeContainers, _ := docker.ListContainers("code")
+	for _, container := range append(serviceContainers, codeContainers...) {
+		util.CaptureLogs(container.Config.Labels["uid"], time.Now().Unix())
+	}
+
 	worker.Process()
 }
@@ -8,16 +8,27 @@
 package main
 
 import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 
-	logapi "github.com/nanobox-io/nanobox-logtap/api"
-	"github.com/nanobox-io/nanobox-logtap/archive"
 	"github.com/nanobox-io/nanobox-logtap/collector"
 	"github.com/nanobox-io/nanobox-logtap/drain"
 	"github.com/nanobox-io/nanobox-router"
 	"github.com/nanobox-io/nanobox-server/api"
 	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
+	"github.com/nanobox-io/nanobox-server/util/archive"
+	"github.com/nanobox-io/nanobox-server/util/cert"
+	"github.com/nanobox-io/nanobox-server/util/drains"
+	"github.com/nanobox-io/nanobox-server/util/events"
+	"github.com/nanobox-io/nanobox-server/util/logquery"
+	"github.com/nanobox-io/nanobox-server/util/pages"
+	"github.com/nanobox-io/nanobox-server/util/webhooks"
 	mistServer "github.com/nanopack/mist/server"
 	"github.com/nanopack/mist/core"
 )
@@ -33,6 +44,12 @@ func main() {
 	// start a mist TCP server listening at 0.0.0.0:1445
 	mistServer.Start([]string{"tcp://0.0.0.0:1445"}, "")
 
+	// publish container events so mist subscribers can follow the containers
+	go events.Watch()
+
+	// tell the webhooks about finished jobs
+	util.JobFinished = webhooks.Notify
+
 	setupLogtap()
 
 	// create new router
@@ -41,6 +58,9 @@ func main() {
 		config.Log.Error("error: %s\n", err.Error())
 	}
 
+	// terminate tls in front of the router
+	setupTLS()
+
 	// initialize the api and set up routing
 	api := api.Init()
 
@@ -52,7 +72,6 @@ func main() {
 }
 
 func setupLogtap() {
-	//
 	console := drain.AdaptLogger(config.Log)
 	config.Logtap.AddDrain("console", console)
 
@@ -73,15 +92,59 @@ func setupLogtap() {
 		panic(err)
 	}
 
-	//
-	db, err := archive.NewBoltArchive("/tmp/bolt.db")
+	// keep the log history on the mounted disk so it survives restarts
+	if err := os.MkdirAll(filepath.Dir(config.LogArchivePath), 0755); err != nil {
+		panic(err)
+	}
+	db, err := archive.Open(config.LogArchivePath)
 	if err != nil {
 		panic(err)
 	}
-	config.LogHandler = logapi.GenerateArchiveEndpoint(db)
+	db.MaxAge = config.LogMaxAge
+	db.MaxSize = config.LogMaxSize
+	go db.Maintain(time.Hour, config.Log)
+	config.LogArchive = db
 
 	//
 	config.Logtap.AddDrain("historical", db.Write)
 	config.Logtap.AddDrain("mist", drain.AdaptPublisher(&mist.Proxy{}))
 
+	// the router pages show the latest deploy logs
+	config.Logtap.AddDrain("pages", pages.Drain)
+
+	// remember the sources so log queries can search all of them
+	config.Logtap.AddDrain("sources", logquery.Track)
+
+	// bring back the drains added through the api
+	drains.Restore()
+
+}
+
+// setupTLS serves https on the router_tls port (where 443 is forwarded to)
+// using certificates from the local development ca and hands the requests to
+// the plain http router.
+func setupTLS() {
+	// the ca only signs for the app domain, its subdomains and localhost
+	domain := config.App() + ".dev"
+	ca, err := cert.Load(config.CertDir, domain, "localhost")
+	if err != nil {
+		config.Log.Error("[nanobox/main.go] Unable to load the certificate authority: %s", err.Error())
+		return
+	}
+	ca.Default = domain
+	config.CertAuthority = ca
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "127.0.0.1:" + config.Ports["router"]})
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Header.Set("X-Forwarded-Proto", "https")
+	}
+
+	listener, err := tls.Listen("tcp", ":"+config.Ports["router_tls"], &tls.Config{GetCertificate: ca.GetCertificate})
+	if err != nil {
+		config.Log.Error("[nanobox/main.go] Unable to start tls: %s", err.Error())
+		return
+	}
+	go http.Serve(listener, proxy)
 }
@@ -0,0 +1,305 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package archive keeps the log history in a bolt database. Every source gets
+// a bucket with the messages keyed by time so the oldest ones can be expired
+// and trimmed without reading the rest.
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/nanobox-io/nanobox-logtap"
+)
+
+// Archive
+type Archive struct {
+	// MaxAge is how long messages are kept, 0 keeps them forever
+	MaxAge time.Duration
+
+	// MaxSize is how big the database file can get in bytes, 0 is no limit
+	MaxSize int64
+
+	path  string
+	mutex sync.RWMutex
+	db    *bolt.DB
+	done  chan struct{}
+}
+
+// Open opens or creates the archive at path
+func Open(path string) (*Archive, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &Archive{path: path, db: db, done: make(chan struct{})}, nil
+}
+
+// Close
+func (a *Archive) Close() error {
+	close(a.done)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.db.Close()
+}
+
+// Write is a logtap drain that stores every message
+func (a *Archive) Write(log logtap.Logger, msg logtap.Message) {
+	if msg.Time.IsZero() {
+		msg.Time = time.Now()
+	}
+	value, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	err = a.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(msg.Type))
+		if err != nil {
+			return err
+		}
+		seq, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key(msg.Time, seq), value)
+	})
+	if err != nil && log != nil {
+		log.Error("[nanobox/archive] Unable to store a log message: %s", err.Error())
+	}
+}
+
+// Slice returns up to limit messages from a source with at least the given
+// priority, newest first, after skipping offset of them
+func (a *Archive) Slice(name string, offset, limit uint64, level int) ([]logtap.Message, error) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	rtn := []logtap.Message{}
+	err := a.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		skipped := uint64(0)
+		for k, v := c.Last(); k != nil && uint64(len(rtn)) < limit; k, v = c.Prev() {
+			msg := logtap.Message{}
+			if err := json.Unmarshal(v, &msg); err != nil || msg.Priority < level {
+				continue
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			rtn = append(rtn, msg)
+		}
+		return nil
+	})
+	return rtn, err
+}
+
+// Clear removes the history of the given sources, or of every source when
+// none are given
+func (a *Archive) Clear(sources ...string) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.db.Update(func(tx *bolt.Tx) error {
+		if len(sources) == 0 {
+			tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				sources = append(sources, string(name))
+				return nil
+			})
+		}
+		for _, source := range sources {
+			if tx.Bucket([]byte(source)) == nil {
+				continue
+			}
+			if err := tx.DeleteBucket([]byte(source)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Expire removes the messages older than MaxAge and returns how many it
+// removed
+func (a *Archive) Expire() (int, error) {
+	if a.MaxAge <= 0 {
+		return 0, nil
+	}
+	cutoff := key(time.Now().Add(-a.MaxAge), 0)
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	removed := 0
+	err := a.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				removed++
+			}
+			return nil
+		})
+	})
+	return removed, err
+}
+
+// Trim drops the oldest tenth of every source until the database fits in
+// MaxSize. The file only shrinks once it is compacted, so Trim compacts as it
+// goes.
+func (a *Archive) Trim() error {
+	if a.MaxSize <= 0 {
+		return nil
+	}
+
+	for i := 0; i < 10 && a.size() > a.MaxSize; i++ {
+		if err := a.dropOldest(10); err != nil {
+			return err
+		}
+		if err := a.Compact(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropOldest removes the oldest 1/fraction of the messages in every source
+func (a *Archive) dropOldest(fraction int) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	return a.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			count := 0
+			b.ForEach(func(k, v []byte) error {
+				count++
+				return nil
+			})
+
+			// small sources still give up a message
+			drop := count / fraction
+			if drop == 0 {
+				drop = 1
+			}
+
+			c := b.Cursor()
+			for k, _ := c.First(); k != nil && drop > 0; k, _ = c.First() {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				drop--
+			}
+			return nil
+		})
+	})
+}
+
+// Compact rewrites the database into a new file so the space freed by removed
+// messages is given back
+func (a *Archive) Compact() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	tmpPath := a.path + ".compact"
+	os.Remove(tmpPath)
+
+	tmp, err := bolt.Open(tmpPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = a.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return tmp.Update(func(tmpTx *bolt.Tx) error {
+				bucket, err := tmpTx.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+				return b.ForEach(func(k, v []byte) error {
+					return bucket.Put(k, v)
+				})
+			})
+		})
+	})
+	if err == nil {
+		// the open handle follows the file, so the old one is only let go
+		// once the new one is in place
+		err = os.Rename(tmpPath, a.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	old := a.db
+	a.db = tmp
+	return old.Close()
+}
+
+// Maintain expires and trims the archive every interval until it is closed,
+// compacting it when that removed anything
+func (a *Archive) Maintain(interval time.Duration, log logtap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-ticker.C:
+		}
+
+		removed, err := a.Expire()
+		if err != nil {
+			log.Error("[nanobox/archive] Unable to expire old logs: %s", err.Error())
+		}
+		if removed > 0 {
+			if err := a.Compact(); err != nil {
+				log.Error("[nanobox/archive] Unable to compact the logs: %s", err.Error())
+			}
+		}
+		if err := a.Trim(); err != nil {
+			log.Error("[nanobox/archive] Unable to trim the logs: %s", err.Error())
+		}
+	}
+}
+
+// size of the database file
+func (a *Archive) size() int64 {
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
+// key sorts messages by time, the sequence keeps messages logged at the same
+// time apart
+func key(t time.Time, seq uint64) []byte {
+	k := make([]byte, 16)
+	binary.BigEndian.PutUint64(k[:8], uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(k[8:], seq)
+	return k
+}
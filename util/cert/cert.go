@@ -0,0 +1,232 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cert keeps a local development certificate authority and issues
+// certificates signed by it for the app's domains.
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structs
+type (
+
+	// Authority signs certificates for the hosts it is allowed to
+	Authority struct {
+		// Allowed decides which host names we are willing to issue for
+		Allowed func(host string) bool
+
+		// Default is used for clients that dont send a server name
+		Default string
+
+		cert    *x509.Certificate
+		key     *rsa.PrivateKey
+		certPEM []byte
+
+		mutex  sync.Mutex
+		issued map[string]*tls.Certificate
+	}
+)
+
+// Load reads the certificate authority from dir and creates a new one the
+// first time so developers only ever need to trust it once. The authority can
+// only sign for the given domains and their subdomains, an authority on disk
+// that was made for other domains is replaced.
+func Load(dir string, domains ...string) (*Authority, error) {
+	certFile := filepath.Join(dir, "ca.pem")
+	keyFile := filepath.Join(dir, "ca.key")
+
+	certPEM, certErr := ioutil.ReadFile(certFile)
+	keyPEM, keyErr := ioutil.ReadFile(keyFile)
+	if certErr != nil || keyErr != nil || !constrained(certPEM, domains) {
+		var err error
+		certPEM, keyPEM, err = newAuthority(domains)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(certFile, certPEM, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the certificate authority key is not an rsa key")
+	}
+
+	a := &Authority{
+		cert:    cert,
+		key:     key,
+		certPEM: certPEM,
+		issued:  map[string]*tls.Certificate{},
+	}
+	a.Allowed = a.Permits
+	return a, nil
+}
+
+// Permits tells if the authority is constrained to sign for host
+func (a *Authority) Permits(host string) bool {
+	for _, domain := range a.cert.PermittedDNSDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// constrained tells if the authority in certPEM is limited to exactly the
+// given domains
+func constrained(certPEM []byte, domains []string) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil || len(cert.PermittedDNSDomains) != len(domains) {
+		return false
+	}
+	for i, domain := range domains {
+		if cert.PermittedDNSDomains[i] != domain {
+			return false
+		}
+	}
+	return true
+}
+
+// PEM returns the certificate authority in PEM format
+func (a *Authority) PEM() []byte {
+	return a.certPEM
+}
+
+// GetCertificate can be used as the tls.Config GetCertificate callback. It
+// issues a certificate for the requested server name the first time it is
+// asked for.
+func (a *Authority) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	host := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if host == "" {
+		host = a.Default
+	}
+	if !a.Allowed(host) {
+		return nil, fmt.Errorf("not issuing a certificate for %s", host)
+	}
+	return a.Certificate(host)
+}
+
+// Certificate returns a certificate for host signed by the authority
+func (a *Authority) Certificate(host string) (*tls.Certificate, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if cert, ok := a.issued[host]; ok && time.Now().Before(cert.Leaf.NotAfter) {
+		return cert, nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial(),
+		Subject:      pkix.Name{CommonName: host, Organization: []string{"nanobox development"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{
+		Certificate: [][]byte{der, a.cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	a.issued[host] = cert
+	return cert, nil
+}
+
+// newAuthority generates a self signed certificate authority that can only
+// sign for the domains
+func newAuthority(domains []string) (certPEM, keyPEM []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial(),
+		Subject:               pkix.Name{CommonName: "nanobox development CA", Organization: []string{"nanobox development"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+
+		// developers trust this authority, keep it from vouching for real sites
+		PermittedDNSDomainsCritical: true,
+		PermittedDNSDomains:         domains,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return certPEM, keyPEM, nil
+}
+
+// serial
+func serial() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return big.NewInt(time.Now().UnixNano())
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package cert_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/nanobox-io/nanobox-server/util/cert"
+)
+
+func TestLoadPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first, err := cert.Load(dir, "app.dev")
+	if err != nil {
+		t.Fatalf("unable to create the authority: %s", err.Error())
+	}
+	second, err := cert.Load(dir, "app.dev")
+	if err != nil {
+		t.Fatalf("unable to load the authority: %s", err.Error())
+	}
+
+	if !bytes.Equal(first.PEM(), second.PEM()) {
+		t.Errorf("the authority should be reused once it exists")
+	}
+
+	third, err := cert.Load(dir, "other.dev")
+	if err != nil {
+		t.Fatalf("unable to replace the authority: %s", err.Error())
+	}
+	if bytes.Equal(first.PEM(), third.PEM()) {
+		t.Errorf("the authority should be replaced for other domains")
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, err := cert.Load(dir, "app.dev", "localhost")
+	if err != nil {
+		t.Fatalf("unable to create the authority: %s", err.Error())
+	}
+
+	issued, err := ca.Certificate("admin.app.dev")
+	if err != nil {
+		t.Fatalf("unable to issue a certificate: %s", err.Error())
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.PEM())
+	if _, err := issued.Leaf.Verify(x509.VerifyOptions{DNSName: "admin.app.dev", Roots: roots}); err != nil {
+		t.Errorf("the certificate does not verify against the authority: %s", err.Error())
+	}
+
+	if ca.Allowed("example.com") || !ca.Allowed("localhost") {
+		t.Errorf("only the app domains and localhost should be allowed")
+	}
+
+	// even a certificate made behind the authority's back isnt trusted
+	outside, err := ca.Certificate("example.com")
+	if err != nil {
+		t.Fatalf("unable to issue a certificate: %s", err.Error())
+	}
+	if _, err := outside.Leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err == nil {
+		t.Errorf("the authority should not vouch for other domains")
+	}
+}
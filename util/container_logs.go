@@ -0,0 +1,78 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/docker"
+)
+
+// captures are the containers whose output is going into logtap, by container
+// id so a container recreated under the same uid gets its own capture
+var captures = map[string]bool{}
+
+var capturesTex = sync.Mutex{}
+
+// CaptureLogs publishes everything a container writes to stdout and stderr
+// into logtap with the container uid as the source. since is a unix timestamp
+// to start from, 0 gets everything. The capture follows the container through
+// restarts and stops once it is removed.
+func CaptureLogs(uid string, since int64) {
+	container, err := docker.InspectContainer(uid)
+	if err != nil {
+		config.Log.Debug("[nanobox/util] capturing %s logs: %s", uid, err.Error())
+		return
+	}
+
+	capturesTex.Lock()
+	defer capturesTex.Unlock()
+
+	if captures[container.ID] {
+		return
+	}
+	captures[container.ID] = true
+
+	go captureLogs(uid, container.ID, since)
+}
+
+// captureLogs
+func captureLogs(uid, id string, since int64) {
+	defer func() {
+		capturesTex.Lock()
+		delete(captures, id)
+		capturesTex.Unlock()
+	}()
+
+	stdout := &LineWriter{Publish: func(line string) { config.Logtap.Publish(uid, 2, line) }}
+	stderr := &LineWriter{Publish: func(line string) { config.Logtap.Publish(uid, 3, line) }}
+
+	for {
+		if err := docker.FollowLogs(id, since, stdout, stderr); err != nil {
+			config.Log.Debug("[nanobox/util] following %s logs: %s", uid, err.Error())
+		}
+		stdout.Flush()
+		stderr.Flush()
+
+		// pick up from where the stream ended so nothing written while the
+		// container restarts is lost
+		since = time.Now().Unix()
+
+		// the stream ends when the container stops, keep going if it is
+		// being restarted
+		<-time.After(time.Second)
+		container, err := docker.InspectContainer(id)
+		if err != nil || container == nil {
+			return
+		}
+		if !container.State.Running {
+			<-time.After(5 * time.Second)
+		}
+	}
+}
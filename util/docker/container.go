@@ -9,6 +9,7 @@ package docker
 //
 import (
 	"fmt"
+	"io"
 	"strings"
 
 	docksig "github.com/docker/docker/pkg/signal"
@@ -22,6 +23,10 @@ type CreateConfig struct {
 	Name     string
 	Cmd      []string
 	Image    string
+
+	// dev containers only
+	LibDirs    []string
+	WorkingDir string
 }
 
 func (d DockerUtil) CreateContainer(conf CreateConfig) (*dc.Container, error) {
@@ -42,12 +47,12 @@ func (d DockerUtil) CreateContainer(conf CreateConfig) (*dc.Container, error) {
 			RestartPolicy: dc.AlwaysRestart(),
 		},
 	}
-	addCategoryConfig(conf.Category, &cConfig)
+	addCategoryConfig(conf, &cConfig)
 	return createContainer(cConfig)
 }
 
-func addCategoryConfig(category string, cConfig *dc.CreateContainerOptions) {
-	switch category {
+func addCategoryConfig(conf CreateConfig, cConfig *dc.CreateContainerOptions) {
+	switch conf.Category {
 	case "dev":
 		cConfig.Config.Cmd = []string{"/bin/sleep", "365d"}
 		cConfig.Config.OpenStdin = true
@@ -55,13 +60,13 @@ func addCategoryConfig(category string, cConfig *dc.CreateContainerOptions) {
 		cConfig.Config.AttachStdout = true
 		cConfig.Config.AttachStderr = true
 		cConfig.Config.WorkingDir = "/code"
-		if WorkingDir != "" {
-			cConfig.Config.WorkingDir = WorkingDir
+		if conf.WorkingDir != "" {
+			cConfig.Config.WorkingDir = conf.WorkingDir
 		}
 		cConfig.Config.User = "gonano"
 		cConfig.HostConfig.Binds = append([]string{
 			config.MountFolder + "code/" + config.App() + "/:/code/",
-		}, LibDirs...)
+		}, conf.LibDirs...)
 		if container, err := GetContainer("build1"); err == nil {
 			cConfig.HostConfig.Binds = append(cConfig.HostConfig.Binds, fmt.Sprintf("/mnt/sda/var/lib/docker/aufs/mnt/%s/data/:/data/", container.ID))
 			cConfig.HostConfig.Binds = append(cConfig.HostConfig.Binds, "/mnt/sda/var/nanobox/build/:/mnt/build/")
@@ -201,3 +206,25 @@ func (d DockerUtil) ListContainers(labels ...string) ([]*dc.Container, error) {
 
 	return rtn, nil
 }
+
+// FollowLogs writes a container's output to out and err until the container
+// stops. since is a unix timestamp, 0 gets everything.
+func (d DockerUtil) FollowLogs(id string, since int64, out io.Writer, err io.Writer) error {
+	return Client.Logs(dc.LogsOptions{
+		Container:    id,
+		OutputStream: out,
+		ErrorStream:  err,
+		Follow:       true,
+		Stdout:       true,
+		Stderr:       true,
+		Since:        since,
+		// our containers all have a tty so the output isnt multiplexed
+		RawTerminal: true,
+	})
+}
+
+// ListenEvents sends every docker event to listener. The listener is closed
+// when docker stops sending events.
+func (d DockerUtil) ListenEvents(listener chan *dc.APIEvents) error {
+	return Client.AddEventListener(listener)
+}
@@ -6,9 +6,6 @@ import (
 	dc "github.com/fsouza/go-dockerclient"
 )
 
-var LibDirs = []string{}
-var WorkingDir = ""
-
 type ClientInterface interface {
 	ListImages(opts dc.ListImagesOptions) ([]dc.APIImages, error)
 	PullImage(opts dc.PullImageOptions, auth dc.AuthConfiguration) error
@@ -25,6 +22,8 @@ type ClientInterface interface {
 	ResizeExecTTY(id string, height, width int) error
 	StartExec(id string, opts dc.StartExecOptions) error
 	InspectExec(id string) (*dc.ExecInspect, error)
+	Logs(opts dc.LogsOptions) error
+	AddEventListener(listener chan<- *dc.APIEvents) error
 }
 
 type DockerDefault interface {
@@ -41,9 +40,12 @@ type DockerDefault interface {
 	ListImages() ([]dc.APIImages, error)
 	ImageExists(name string) bool
 	ExecInContainer(container string, args ...string) ([]byte, error)
+	StreamExecInContainer(container string, stdout, stderr io.Writer, args ...string) ([]byte, error)
 	CreateExec(id string, cmd []string, in, out, err bool) (*dc.Exec, error)
 	ResizeExecTTY(id string, height, width int) error
 	RunExec(exec *dc.Exec, in io.Reader, out io.Writer, err io.Writer) (*dc.ExecInspect, error)
+	FollowLogs(id string, since int64, out io.Writer, err io.Writer) error
+	ListenEvents(listener chan *dc.APIEvents) error
 }
 
 type DockerUtil struct {
@@ -97,6 +99,9 @@ func ListContainers(labels ...string) ([]*dc.Container, error) {
 func ExecInContainer(container string, args ...string) ([]byte, error) {
 	return Default.ExecInContainer(container, args...)
 }
+func StreamExecInContainer(container string, stdout, stderr io.Writer, args ...string) ([]byte, error) {
+	return Default.StreamExecInContainer(container, stdout, stderr, args...)
+}
 func CreateExec(id string, cmd []string, in, out, err bool) (*dc.Exec, error) {
 	return Default.CreateExec(id, cmd, in, out, err)
 }
@@ -106,3 +111,9 @@ func ResizeExecTTY(id string, height, width int) error {
 func RunExec(exec *dc.Exec, in io.Reader, out io.Writer, err io.Writer) (*dc.ExecInspect, error) {
 	return Default.RunExec(exec, in, out, err)
 }
+func FollowLogs(id string, since int64, out io.Writer, err io.Writer) error {
+	return Default.FollowLogs(id, since, out, err)
+}
+func ListenEvents(listener chan *dc.APIEvents) error {
+	return Default.ListenEvents(listener)
+}
@@ -11,6 +11,12 @@ import (
 
 // Exec
 func (d DockerUtil) ExecInContainer(container string, args ...string) ([]byte, error) {
+	return d.StreamExecInContainer(container, nil, nil, args...)
+}
+
+// StreamExecInContainer runs a command like ExecInContainer and also copies
+// its output to stdout and stderr as it is written. Either can be nil.
+func (d DockerUtil) StreamExecInContainer(container string, stdout, stderr io.Writer, args ...string) ([]byte, error) {
 	opts := dc.CreateExecOptions{
 		AttachStdout: true,
 		AttachStderr: true,
@@ -25,7 +31,15 @@ func (d DockerUtil) ExecInContainer(container string, args ...string) ([]byte, e
 	}
 	b := &bytes.Buffer{}
 
-	results, err := RunExec(exec, nil, b, b)
+	out, errOut := io.Writer(b), io.Writer(b)
+	if stdout != nil {
+		out = io.MultiWriter(b, stdout)
+	}
+	if stderr != nil {
+		errOut = io.MultiWriter(b, stderr)
+	}
+
+	results, err := RunExec(exec, nil, out, errOut)
 
 	// if 'no such file or directory' squash the error
 	if strings.Contains(b.String(), "no such file or directory") {
@@ -188,6 +188,26 @@ func (_mr *_MockClientInterfaceRecorder) InspectExec(arg0 interface{}) *gomock.C
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "InspectExec", arg0)
 }
 
+func (_m *MockClientInterface) Logs(opts go_dockerclient.LogsOptions) error {
+	ret := _m.ctrl.Call(_m, "Logs", opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientInterfaceRecorder) Logs(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Logs", arg0)
+}
+
+func (_m *MockClientInterface) AddEventListener(listener chan<- *go_dockerclient.APIEvents) error {
+	ret := _m.ctrl.Call(_m, "AddEventListener", listener)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockClientInterfaceRecorder) AddEventListener(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "AddEventListener", arg0)
+}
+
 // Mock of DockerDefault interface
 type MockDockerDefault struct {
 	ctrl     *gomock.Controller
@@ -355,6 +375,22 @@ func (_mr *_MockDockerDefaultRecorder) ExecInContainer(arg0 interface{}, arg1 ..
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ExecInContainer", _s...)
 }
 
+func (_m *MockDockerDefault) StreamExecInContainer(container string, stdout io.Writer, stderr io.Writer, args ...string) ([]byte, error) {
+	_s := []interface{}{container, stdout, stderr}
+	for _, _x := range args {
+		_s = append(_s, _x)
+	}
+	ret := _m.ctrl.Call(_m, "StreamExecInContainer", _s...)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDockerDefaultRecorder) StreamExecInContainer(arg0, arg1, arg2 interface{}, arg3 ...interface{}) *gomock.Call {
+	_s := append([]interface{}{arg0, arg1, arg2}, arg3...)
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "StreamExecInContainer", _s...)
+}
+
 func (_m *MockDockerDefault) CreateExec(id string, cmd []string, in bool, out bool, err bool) (*go_dockerclient.Exec, error) {
 	ret := _m.ctrl.Call(_m, "CreateExec", id, cmd, in, out, err)
 	ret0, _ := ret[0].(*go_dockerclient.Exec)
@@ -386,3 +422,23 @@ func (_m *MockDockerDefault) RunExec(exec *go_dockerclient.Exec, in io.Reader, o
 func (_mr *_MockDockerDefaultRecorder) RunExec(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "RunExec", arg0, arg1, arg2, arg3)
 }
+
+func (_m *MockDockerDefault) FollowLogs(id string, since int64, out io.Writer, err io.Writer) error {
+	ret := _m.ctrl.Call(_m, "FollowLogs", id, since, out, err)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerDefaultRecorder) FollowLogs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "FollowLogs", arg0, arg1, arg2, arg3)
+}
+
+func (_m *MockDockerDefault) ListenEvents(listener chan *go_dockerclient.APIEvents) error {
+	ret := _m.ctrl.Call(_m, "ListenEvents", listener)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerDefaultRecorder) ListenEvents(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ListenEvents", arg0)
+}
@@ -0,0 +1,302 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package drains sends the logs to the places added at runtime: a rotating
+// file, a remote syslog server or a http endpoint. The drains are kept in a
+// registry so they come back after a restart.
+package drains
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// Drain describes where the logs are sent
+type Drain struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// file
+	Path     string `json:"path,omitempty"`
+	MaxSize  int64  `json:"max_size,omitempty"`
+	MaxFiles int    `json:"max_files,omitempty"`
+
+	// syslog
+	Protocol string `json:"protocol,omitempty"`
+	Address  string `json:"address,omitempty"`
+
+	// http
+	URL string `json:"url,omitempty"`
+}
+
+// the drain types
+const (
+	File   = "file"
+	Syslog = "syslog"
+	HTTP   = "http"
+)
+
+// defaults for file drains
+const (
+	DefaultMaxSize  = 10 << 20
+	DefaultMaxFiles = 5
+)
+
+// queueSize is how many messages wait for a slow drain before they are dropped
+const queueSize = 1000
+
+// maxBatch is the most messages handed to a drain at once
+const maxBatch = 100
+
+// sender delivers messages to a drain's destination
+type sender interface {
+	send(msgs []logtap.Message) error
+	close()
+}
+
+// running is a drain that is receiving messages
+type running struct {
+	Drain
+
+	out   sender
+	queue chan logtap.Message
+	done  chan struct{}
+}
+
+var names = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+var drains = map[string]*running{}
+
+var drainsTex = sync.Mutex{}
+
+// Add validates a drain, starts sending it the logs and saves it
+func Add(drain Drain) (Drain, error) {
+	drainsTex.Lock()
+	defer drainsTex.Unlock()
+
+	if _, ok := drains[drain.Name]; ok {
+		return drain, fmt.Errorf("a drain named %q already exists", drain.Name)
+	}
+
+	drain, err := start(drain)
+	if err != nil {
+		return drain, err
+	}
+
+	if err := save(); err != nil {
+		config.Log.Error("[nanobox/drains] Unable to save the drains: %s", err.Error())
+	}
+	return drain, nil
+}
+
+// Remove stops sending logs to a drain and forgets it
+func Remove(name string) error {
+	drainsTex.Lock()
+	defer drainsTex.Unlock()
+
+	r, ok := drains[name]
+	if !ok {
+		return fmt.Errorf("there is no drain named %q", name)
+	}
+
+	config.Logtap.RemoveDrain(tag(name))
+	close(r.done)
+	delete(drains, name)
+
+	if err := save(); err != nil {
+		config.Log.Error("[nanobox/drains] Unable to save the drains: %s", err.Error())
+	}
+	return nil
+}
+
+// List the drains by name
+func List() []Drain {
+	drainsTex.Lock()
+	defer drainsTex.Unlock()
+
+	rtn := []Drain{}
+	for _, r := range drains {
+		rtn = append(rtn, r.Drain)
+	}
+	sort.Sort(byName(rtn))
+	return rtn
+}
+
+// Restore starts the drains in the registry
+func Restore() {
+	drainsTex.Lock()
+	defer drainsTex.Unlock()
+
+	b, err := ioutil.ReadFile(config.DrainRegistry)
+	if err != nil {
+		return
+	}
+
+	saved := []Drain{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		config.Log.Error("[nanobox/drains] Unable to read the drains: %s", err.Error())
+		return
+	}
+
+	for _, drain := range saved {
+		if _, ok := drains[drain.Name]; ok {
+			continue
+		}
+		if _, err := start(drain); err != nil {
+			config.Log.Error("[nanobox/drains] Unable to restore the %s drain: %s", drain.Name, err.Error())
+		}
+	}
+}
+
+// start expects the caller to hold the drainsTex lock
+func start(drain Drain) (Drain, error) {
+	if err := validate(&drain); err != nil {
+		return drain, err
+	}
+
+	var out sender
+	var err error
+	switch drain.Type {
+	case File:
+		out, err = newFileSender(drain)
+	case Syslog:
+		out = newSyslogSender(drain)
+	case HTTP:
+		out = newHTTPSender(drain)
+	}
+	if err != nil {
+		return drain, err
+	}
+
+	r := &running{Drain: drain, out: out, queue: make(chan logtap.Message, queueSize), done: make(chan struct{})}
+	go r.run()
+
+	drains[drain.Name] = r
+	config.Logtap.AddDrain(tag(drain.Name), r.write)
+	return drain, nil
+}
+
+// validate checks a drain and fills in its defaults
+func validate(drain *Drain) error {
+	if !names.MatchString(drain.Name) {
+		return fmt.Errorf("a drain needs a name made of letters, numbers, - and _")
+	}
+
+	switch drain.Type {
+	case File:
+		// file drains stay in the drain dir, a relative path is taken from it
+		path := drain.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(config.DrainDir, path)
+		}
+		rel, err := filepath.Rel(config.DrainDir, path)
+		if drain.Path == "" || err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("a file drain needs a path in %s", config.DrainDir)
+		}
+		drain.Path = filepath.Clean(path)
+		if drain.MaxSize <= 0 {
+			drain.MaxSize = DefaultMaxSize
+		}
+		if drain.MaxFiles <= 0 {
+			drain.MaxFiles = DefaultMaxFiles
+		}
+	case Syslog:
+		if drain.Protocol == "" {
+			drain.Protocol = "udp"
+		}
+		if drain.Protocol != "udp" && drain.Protocol != "tcp" {
+			return fmt.Errorf("a syslog drain sends over udp or tcp, not %q", drain.Protocol)
+		}
+		if _, _, err := net.SplitHostPort(drain.Address); err != nil {
+			return fmt.Errorf("a syslog drain needs a host:port address")
+		}
+	case HTTP:
+		u, err := url.Parse(drain.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("a http drain needs a http or https url")
+		}
+	default:
+		return fmt.Errorf("%q is not a drain type, use file, syslog or http", drain.Type)
+	}
+	return nil
+}
+
+// write is the logtap drain, it queues the message so a slow destination
+// doesnt hold up the other drains
+func (r *running) write(log logtap.Logger, msg logtap.Message) {
+	select {
+	case r.queue <- msg:
+	default:
+	}
+}
+
+// run sends the queued messages in batches until the drain is removed
+func (r *running) run() {
+	for {
+		select {
+		case <-r.done:
+			r.out.close()
+			return
+		case msg := <-r.queue:
+			batch := []logtap.Message{msg}
+		fill:
+			for len(batch) < maxBatch {
+				select {
+				case msg := <-r.queue:
+					batch = append(batch, msg)
+				default:
+					break fill
+				}
+			}
+			if err := r.out.send(batch); err != nil {
+				config.Log.Warn("[nanobox/drains] Unable to send logs to the %s drain: %s", r.Name, err.Error())
+			}
+		}
+	}
+}
+
+// save expects the caller to hold the drainsTex lock
+func save() error {
+	saved := []Drain{}
+	for _, r := range drains {
+		saved = append(saved, r.Drain)
+	}
+	sort.Sort(byName(saved))
+
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(config.DrainRegistry), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(config.DrainRegistry, b, 0644)
+}
+
+// tag keeps the added drains apart from the builtin ones in logtap
+func tag(name string) string {
+	return "drain-" + name
+}
+
+// byName
+type byName []Drain
+
+func (d byName) Len() int           { return len(d) }
+func (d byName) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d byName) Less(i, j int) bool { return d[i].Name < d[j].Name }
@@ -0,0 +1,92 @@
+package drains_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/drains"
+)
+
+func TestAdd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "drains")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.DrainRegistry = filepath.Join(dir, "drains.json")
+	config.DrainDir = filepath.Join(dir, "logs")
+
+	invalid := []drains.Drain{
+		{Name: "no spaces", Type: drains.File, Path: "app.log"},
+		{Name: "file", Type: drains.File, Path: "../app.log"},
+		{Name: "file", Type: drains.File, Path: "/etc/app.log"},
+		{Name: "file", Type: drains.File},
+		{Name: "syslog", Type: drains.Syslog, Protocol: "sctp", Address: "127.0.0.1:514"},
+		{Name: "syslog", Type: drains.Syslog, Address: "localhost"},
+		{Name: "http", Type: drains.HTTP, URL: "ftp://example.com"},
+		{Name: "other", Type: "kafka"},
+	}
+	for _, drain := range invalid {
+		if _, err := drains.Add(drain); err == nil {
+			t.Errorf("%+v should not be a valid drain", drain)
+		}
+	}
+
+	file, err := drains.Add(drains.Drain{Name: "file", Type: drains.File, Path: "app.log"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Path != filepath.Join(dir, "logs", "app.log") {
+		t.Errorf("the file drain should be in the drain dir not %s", file.Path)
+	}
+	if file.MaxSize != drains.DefaultMaxSize || file.MaxFiles != drains.DefaultMaxFiles {
+		t.Errorf("the file drain should have the default limits (%+v)", file)
+	}
+	syslog, _ := drains.Add(drains.Drain{Name: "syslog", Type: drains.Syslog, Address: "127.0.0.1:514"})
+	if syslog.Protocol != "udp" {
+		t.Errorf("syslog should default to udp not %q", syslog.Protocol)
+	}
+	if _, err := drains.Add(drains.Drain{Name: "file", Type: drains.HTTP, URL: "http://example.com"}); err == nil {
+		t.Errorf("a drain name should only be used once")
+	}
+
+	list := drains.List()
+	if len(list) != 2 || list[0].Name != "file" || list[1].Name != "syslog" {
+		t.Errorf("both drains should be listed (%+v)", list)
+	}
+
+	if err := drains.Remove("syslog"); err != nil {
+		t.Fatal(err)
+	}
+	if err := drains.Remove("syslog"); err == nil {
+		t.Errorf("removing a missing drain should fail")
+	}
+
+	saved := []drains.Drain{}
+	b, _ := ioutil.ReadFile(config.DrainRegistry)
+	if err := json.Unmarshal(b, &saved); err != nil || len(saved) != 1 || saved[0].Name != "file" {
+		t.Errorf("the registry should only have the file drain (%s)", b)
+	}
+	drains.Remove("file")
+}
+
+func TestFormatSyslog(t *testing.T) {
+	msg := logtap.Message{
+		Type:     "web 1",
+		Time:     time.Date(2015, 3, 4, 5, 6, 7, 8000, time.UTC),
+		Priority: 4,
+		Content:  "GET / failed\n",
+	}
+	line := drains.FormatSyslog(msg, "")
+	expected := "<131>1 2015-03-04T05:06:07.000008Z - web_1 - - - GET / failed"
+	if line != expected {
+		t.Errorf("expected %q but got %q", expected, line)
+	}
+}
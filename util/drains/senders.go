@@ -0,0 +1,222 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package drains
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/logquery"
+)
+
+// timeout for reaching syslog and http drains
+const timeout = 10 * time.Second
+
+// fileSender writes the logs as text and rotates the file once it reaches
+// MaxSize, keeping MaxFiles old ones as path.1, path.2...
+type fileSender struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+// newFileSender
+func newFileSender(drain Drain) (*fileSender, error) {
+	f := &fileSender{path: drain.Path, maxSize: drain.MaxSize, maxFiles: drain.MaxFiles}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, err
+	}
+	return f, f.open()
+}
+
+// open
+func (f *fileSender) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, fi.Size()
+	return nil
+}
+
+// send
+func (f *fileSender) send(msgs []logtap.Message) error {
+	for _, msg := range msgs {
+		line := logquery.Format(msg, "text")
+		if f.size > 0 && f.size+int64(len(line)) > f.maxSize {
+			if err := f.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := io.WriteString(f.file, line)
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate moves every file up one and starts a new one, the oldest falls off
+func (f *fileSender) rotate() error {
+	f.file.Close()
+	for i := f.maxFiles - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	err := os.Rename(f.path, f.path+".1")
+
+	// keep writing to the path even when the rotation failed
+	if openErr := f.open(); err == nil {
+		err = openErr
+	}
+	return err
+}
+
+// close
+func (f *fileSender) close() {
+	f.file.Close()
+}
+
+// syslogSender sends RFC5424 messages, over tcp they are octet counted
+// (RFC6587). It reconnects on the next batch when a send fails.
+type syslogSender struct {
+	protocol string
+	address  string
+
+	conn net.Conn
+}
+
+// newSyslogSender
+func newSyslogSender(drain Drain) *syslogSender {
+	return &syslogSender{protocol: drain.Protocol, address: drain.Address}
+}
+
+// send
+func (s *syslogSender) send(msgs []logtap.Message) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.protocol, s.address, timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	hostname := config.App()
+	for _, msg := range msgs {
+		line := FormatSyslog(msg, hostname)
+		if s.protocol == "tcp" {
+			line = fmt.Sprintf("%d %s", len(line), line)
+		}
+
+		s.conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := io.WriteString(s.conn, line); err != nil {
+			s.close()
+			return err
+		}
+	}
+	return nil
+}
+
+// close
+func (s *syslogSender) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// severities are the syslog severities of the logtap priorities
+var severities = []int{7, 7, 6, 4, 3, 2}
+
+// facility is local0
+const facility = 16
+
+// FormatSyslog writes a message as RFC5424 with the source as the app name
+func FormatSyslog(msg logtap.Message, hostname string) string {
+	severity := 6
+	if msg.Priority >= 0 && msg.Priority < len(severities) {
+		severity = severities[msg.Priority]
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - - - %s",
+		facility*8+severity,
+		msg.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		header(hostname, 255),
+		header(msg.Type, 48),
+		strings.TrimRight(msg.Content, "\n"),
+	)
+}
+
+// header makes a value fit a syslog header field, which is printable ascii
+// without spaces and has a max length. Empty values are "-".
+func header(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// httpSender posts every batch as a json array
+type httpSender struct {
+	url    string
+	client *http.Client
+}
+
+// newHTTPSender
+func newHTTPSender(drain Drain) *httpSender {
+	return &httpSender{url: drain.URL, client: &http.Client{Timeout: timeout}}
+}
+
+// send
+func (h *httpSender) send(msgs []logtap.Message) error {
+	b, err := json.Marshal(msgs)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.client.Post(h.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with %s", h.url, res.Status)
+	}
+	return nil
+}
+
+// close
+func (h *httpSender) close() {}
@@ -0,0 +1,209 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package events publishes what happens to the app's containers to mist under
+// the "container" and "<uid>" tags. Every event is a json document:
+//
+//   {
+//     "version":   1,                            // bumped on breaking changes
+//     "event":     "died",                       // created, started, died, removed or health-changed
+//     "uid":       "web1",                       // the container's uid
+//     "id":        "4c01db0b339c...",            // the docker container id
+//     "category":  "code",                       // code, service, build, dev...
+//     "time":      "2015-03-04T05:06:07Z",       // RFC3339
+//     "exit_code": 137,                          // died only
+//     "healthy":   false,                        // health-changed only
+//     "target":    "http://172.17.0.5:8080",     // health-changed only, the address checked
+//     "error":     "/ responded with 502 ..."    // health-changed only, why the check failed
+//   }
+//
+// Containers that nanobox didnt create (no uid label) are left out.
+package events
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	dc "github.com/fsouza/go-dockerclient"
+	"github.com/nanopack/mist/core"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/docker"
+)
+
+// Version of the event document
+const Version = 1
+
+// the events
+const (
+	Created       = "created"
+	Started       = "started"
+	Died          = "died"
+	Removed       = "removed"
+	HealthChanged = "health-changed"
+)
+
+// Event is a change to a container
+type Event struct {
+	Version  int       `json:"version"`
+	Event    string    `json:"event"`
+	UID      string    `json:"uid"`
+	ID       string    `json:"id,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Time     time.Time `json:"time"`
+	ExitCode *int      `json:"exit_code,omitempty"`
+	Healthy  *bool     `json:"healthy,omitempty"`
+	Target   string    `json:"target,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// container is what is remembered about a container so its removal can be
+// published after docker forgot it
+type container struct {
+	uid      string
+	category string
+}
+
+var containers = map[string]container{}
+
+var containersTex = sync.Mutex{}
+
+// Publish sends an event to mist
+func Publish(event Event) {
+	event.Version = Version
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := mist.Publish([]string{"container", event.UID}, string(b)); err != nil {
+		config.Log.Debug("[nanobox/events] Unable to publish a %s event: %s", event.Event, err.Error())
+	}
+}
+
+// Health publishes the health of one of a container's targets changing
+func Health(uid, target string, healthy bool, reason string) {
+	Publish(Event{Event: HealthChanged, UID: uid, Target: target, Healthy: &healthy, Error: reason})
+}
+
+// Watch publishes the docker events of our containers. It keeps listening
+// when docker goes away and comes back.
+func Watch() {
+	for {
+		remember()
+
+		listener := make(chan *dc.APIEvents, 100)
+		if err := docker.ListenEvents(listener); err != nil {
+			config.Log.Error("[nanobox/events] Unable to listen to docker events: %s", err.Error())
+		} else {
+			for event := range listener {
+				handle(event)
+			}
+		}
+		<-time.After(5 * time.Second)
+	}
+}
+
+// remember the containers that exist before their events come in
+func remember() {
+	list, err := docker.ListContainers()
+	if err != nil {
+		return
+	}
+
+	containersTex.Lock()
+	defer containersTex.Unlock()
+
+	for _, c := range list {
+		if uid := c.Config.Labels["uid"]; uid != "" {
+			containers[c.ID] = container{uid: uid, category: categoryOf(c.Config.Labels)}
+		}
+	}
+}
+
+// categories are the kinds of container, the one a container is has a "true"
+// label
+var categories = []string{"code", "service", "build", "bootstrap", "dev", "tcp", "udp"}
+
+// categoryOf
+func categoryOf(labels map[string]string) string {
+	for _, category := range categories {
+		if labels[category] == "true" {
+			return category
+		}
+	}
+	return ""
+}
+
+// handle turns a docker event into ours
+func handle(event *dc.APIEvents) {
+	if event == nil {
+		return
+	}
+
+	e := Event{ID: event.ID, Time: time.Unix(event.Time, 0)}
+	switch {
+	case event.Status == "create":
+		e.Event = Created
+	case event.Status == "start":
+		e.Event = Started
+	case event.Status == "die":
+		e.Event = Died
+	case event.Status == "destroy":
+		e.Event = Removed
+	case strings.HasPrefix(event.Status, "health_status:"):
+		healthy := strings.TrimSpace(strings.TrimPrefix(event.Status, "health_status:")) == "healthy"
+		e.Event, e.Healthy = HealthChanged, &healthy
+	default:
+		return
+	}
+
+	c, ok := lookup(event.ID, e.Event == Removed)
+	if !ok {
+		return
+	}
+	e.UID, e.Category = c.uid, c.category
+
+	if e.Event == Died {
+		if info, err := docker.InspectContainer(event.ID); err == nil && info != nil {
+			exitCode := info.State.ExitCode
+			e.ExitCode = &exitCode
+		}
+	}
+
+	Publish(e)
+}
+
+// lookup finds the uid of a container, asking docker when it isnt known yet.
+// A removed container is forgotten.
+func lookup(id string, forget bool) (container, bool) {
+	containersTex.Lock()
+	c, ok := containers[id]
+	if forget {
+		delete(containers, id)
+	}
+	containersTex.Unlock()
+
+	if ok || forget {
+		return c, ok
+	}
+
+	info, err := docker.InspectContainer(id)
+	if err != nil || info == nil || info.Config == nil || info.Config.Labels["uid"] == "" {
+		return c, false
+	}
+	c = container{uid: info.Config.Labels["uid"], category: categoryOf(info.Config.Labels)}
+
+	containersTex.Lock()
+	containers[id] = c
+	containersTex.Unlock()
+	return c, true
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package forward moves tcp and udp traffic from ports on the host to the
+// containers. It is done with kernel IPVS when the host has it, or with a
+// userspace proxy when it doesnt.
+package forward
+
+import (
+	"fmt"
+
+	"github.com/nanobox-io/golang-lvs"
+)
+
+// Forwarder forwards ports on the host to container ports. Forwards are listed
+// as lvs services whichever way they are done.
+type Forwarder interface {
+	Add(netType string, fromPort int, toIp string, toPort int) error
+	Remove(ip string) error
+	List() []lvs.Service
+}
+
+// New creates the forwarder with the given name. "auto" uses IPVS and falls
+// back to the userspace proxy when IPVS is unavailable.
+func New(name, host string) (Forwarder, error) {
+	switch name {
+	case "ipvs":
+		return NewIPVS(host)
+	case "userspace":
+		return NewUserspace(host), nil
+	case "", "auto":
+		if ipvs, err := NewIPVS(host); err == nil {
+			return ipvs, nil
+		}
+		return NewUserspace(host), nil
+	}
+	return nil, fmt.Errorf("%q is not a forwarder, use ipvs, userspace or auto", name)
+}
@@ -0,0 +1,116 @@
+package forward_test
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/util/forward"
+)
+
+func TestUserspaceTCP(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				conn.Write([]byte("echo " + line))
+			}()
+		}
+	}()
+
+	u := forward.NewUserspace("127.0.0.1")
+	port := freePort(t, "tcp")
+	if err := u.Add("tcp", port, "127.0.0.1", backend.Addr().(*net.TCPAddr).Port); err != nil {
+		t.Fatal(err)
+	}
+	defer u.Remove("127.0.0.1")
+
+	conn, err := net.Dial("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("hello\n"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "echo hello\n" {
+		t.Errorf("the forward should have echoed hello but got %q (%v)", line, err)
+	}
+
+	if services := u.List(); len(services) != 1 || services[0].Port != port {
+		t.Errorf("the forward is not listed (%+v)", services)
+	}
+}
+
+func TestUserspaceUDP(t *testing.T) {
+	backend, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.Close()
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := backend.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			backend.WriteTo(append([]byte("echo "), buf[:n]...), addr)
+		}
+	}()
+
+	u := forward.NewUserspace("127.0.0.1")
+	port := freePort(t, "udp")
+	if err := u.Add("udp", port, "127.0.0.1", backend.LocalAddr().(*net.UDPAddr).Port); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("udp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write([]byte("hello"))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "echo hello" {
+		t.Errorf("the forward should have echoed hello but got %q (%v)", buf[:n], err)
+	}
+
+	u.Remove("127.0.0.1")
+	if services := u.List(); len(services) != 0 {
+		t.Errorf("the forward should be gone (%+v)", services)
+	}
+}
+
+// freePort finds a port nothing is listening on
+func freePort(t *testing.T, netType string) int {
+	if netType == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
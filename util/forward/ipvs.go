@@ -0,0 +1,69 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package forward
+
+import (
+	"fmt"
+
+	"github.com/nanobox-io/golang-lvs"
+)
+
+// IPVS forwards ports with kernel IPVS, it needs the ip_vs module and root
+type IPVS struct {
+	Host string
+}
+
+// NewIPVS
+func NewIPVS(host string) (*IPVS, error) {
+	if err := lvs.DefaultIpvs.Save(); err != nil {
+		return nil, err
+	}
+	return &IPVS{Host: host}, nil
+}
+
+// Add
+func (i *IPVS) Add(netType string, fromPort int, toIp string, toPort int) error {
+	err := lvs.DefaultIpvs.AddService(lvs.Service{Host: i.Host, Port: fromPort, Type: netType, Persistence: 300})
+	if err != nil {
+		return err
+	}
+	// look up the service instead of using the one we created
+	// this keeps the communication with the lvs package simple
+	service := lvs.DefaultIpvs.FindService(netType, i.Host, fromPort)
+	if service == nil {
+		return fmt.Errorf("the %s service on port %d was not created", netType, fromPort)
+	}
+
+	server := lvs.Server{Host: toIp, Port: toPort, Weight: 1, Forwarder: "m"}
+	return service.AddServer(server)
+}
+
+// Remove removes every service forwarding to ip
+func (i *IPVS) Remove(ip string) error {
+	// find the services first, removing them changes the list we loop over
+	remove := []lvs.Service{}
+	for _, service := range lvs.DefaultIpvs.Services {
+		for _, server := range service.Servers {
+			if server.Host == ip {
+				remove = append(remove, service)
+				break
+			}
+		}
+	}
+
+	for _, service := range remove {
+		if err := lvs.DefaultIpvs.RemoveService(service.Type, service.Host, service.Port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// List
+func (i *IPVS) List() []lvs.Service {
+	return lvs.DefaultIpvs.Services
+}
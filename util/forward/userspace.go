@@ -0,0 +1,260 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package forward
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nanobox-io/golang-lvs"
+)
+
+// UDPTimeout is how long a udp client can be quiet before its session with
+// the container is dropped
+var UDPTimeout = time.Minute
+
+// structs
+type (
+
+	// Userspace forwards ports by proxying the traffic itself. It is slower
+	// than IPVS but runs anywhere.
+	Userspace struct {
+		Host string
+
+		mutex    sync.Mutex
+		services map[string]*service
+	}
+
+	// service is a single listening port
+	service struct {
+		lvs.Service
+
+		mutex    sync.RWMutex
+		next     uint64
+		listener net.Listener
+		conn     net.PacketConn
+	}
+)
+
+// NewUserspace
+func NewUserspace(host string) *Userspace {
+	return &Userspace{
+		Host:     host,
+		services: map[string]*service{},
+	}
+}
+
+// Add starts listening on fromPort the first time it is forwarded, forwarding
+// it again adds another server to balance over
+func (u *Userspace) Add(netType string, fromPort int, toIp string, toPort int) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	server := lvs.Server{Host: toIp, Port: toPort, Weight: 1}
+
+	key := serviceKey(netType, fromPort)
+	if s, ok := u.services[key]; ok {
+		s.mutex.Lock()
+		s.Servers = append(s.Servers, server)
+		s.mutex.Unlock()
+		return nil
+	}
+
+	s := &service{Service: lvs.Service{Host: u.Host, Port: fromPort, Type: netType, Servers: []lvs.Server{server}}}
+	address := net.JoinHostPort(u.Host, strconv.Itoa(fromPort))
+
+	switch netType {
+	case "tcp":
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		s.listener = listener
+		go s.serveTCP()
+	case "udp":
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		go s.serveUDP()
+	default:
+		return fmt.Errorf("cant forward %s ports", netType)
+	}
+
+	u.services[key] = s
+	return nil
+}
+
+// Remove stops every service forwarding to ip
+func (u *Userspace) Remove(ip string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	for key, s := range u.services {
+		s.mutex.RLock()
+		found := false
+		for _, server := range s.Servers {
+			if server.Host == ip {
+				found = true
+				break
+			}
+		}
+		s.mutex.RUnlock()
+
+		if found {
+			s.close()
+			delete(u.services, key)
+		}
+	}
+	return nil
+}
+
+// List
+func (u *Userspace) List() []lvs.Service {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	rtn := []lvs.Service{}
+	for _, s := range u.services {
+		s.mutex.RLock()
+		svc := s.Service
+		svc.Servers = append([]lvs.Server{}, s.Servers...)
+		s.mutex.RUnlock()
+		rtn = append(rtn, svc)
+	}
+	return rtn
+}
+
+// serviceKey
+func serviceKey(netType string, port int) string {
+	return netType + ":" + strconv.Itoa(port)
+}
+
+// pick the next server round robin
+func (s *service) pick() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := atomic.AddUint64(&s.next, 1)
+	server := s.Servers[n%uint64(len(s.Servers))]
+	return net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+}
+
+// close
+func (s *service) close() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// serveTCP copies every connection to a server until the listener is closed
+func (s *service) serveTCP() {
+	for {
+		client, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.proxyTCP(client)
+	}
+}
+
+// proxyTCP
+func (s *service) proxyTCP(client net.Conn) {
+	defer client.Close()
+
+	server, err := net.DialTimeout("tcp", s.pick(), 10*time.Second)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		// let the other side know we are done writing
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(server, client)
+	go pipe(client, server)
+	<-done
+	<-done
+}
+
+// serveUDP gives every client its own connection to a server so the replies
+// can be sent back to the right client
+func (s *service) serveUDP() {
+	sessions := map[string]net.Conn{}
+	sessionsTex := sync.Mutex{}
+
+	buf := make([]byte, 65535)
+	for {
+		n, client, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			sessionsTex.Lock()
+			for _, session := range sessions {
+				session.Close()
+			}
+			sessionsTex.Unlock()
+			return
+		}
+
+		sessionsTex.Lock()
+		session, ok := sessions[client.String()]
+		if !ok {
+			session, err = net.Dial("udp", s.pick())
+			if err != nil {
+				sessionsTex.Unlock()
+				continue
+			}
+			sessions[client.String()] = session
+
+			go func(client net.Addr, session net.Conn) {
+				s.replyUDP(client, session)
+
+				// closed under the lock so it isnt closed in the middle of a write
+				sessionsTex.Lock()
+				if sessions[client.String()] == session {
+					delete(sessions, client.String())
+				}
+				session.Close()
+				sessionsTex.Unlock()
+			}(client, session)
+		}
+
+		session.SetReadDeadline(time.Now().Add(UDPTimeout))
+		session.Write(buf[:n])
+		sessionsTex.Unlock()
+	}
+}
+
+// replyUDP sends what the server says back to the client until the session
+// times out
+func (s *service) replyUDP(client net.Addr, session net.Conn) {
+	buf := make([]byte, 65535)
+	for {
+		n, err := session.Read(buf)
+		if err != nil {
+			return
+		}
+		session.SetReadDeadline(time.Now().Add(UDPTimeout))
+		if _, err := s.conn.WriteTo(buf[:n], client); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package health periodically checks http targets and keeps track of which of
+// them are able to take requests.
+package health
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structs
+type (
+
+	// Check describes how a target is checked. A target is ejected after Fall
+	// failed checks in a row and restored after Rise good ones.
+	Check struct {
+		Path     string
+		Interval time.Duration
+		Timeout  time.Duration
+		Rise     int
+		Fall     int
+	}
+
+	// Status is the last known health of a target
+	Status struct {
+		Target  string    `json:"target"`
+		Healthy bool      `json:"healthy"`
+		Checked time.Time `json:"checked"`
+		Error   string    `json:"error,omitempty"`
+	}
+
+	// Checker runs the checks for a set of targets
+	Checker struct {
+		// OnChange is called with the new status whenever a target goes from
+		// healthy to unhealthy or back
+		OnChange func(Status)
+
+		mutex   sync.Mutex
+		targets map[string]*state
+	}
+
+	// state
+	state struct {
+		Status
+		check  Check
+		rises  int
+		falls  int
+		client *http.Client
+		done   chan struct{}
+	}
+)
+
+// DefaultCheck is used for anything left empty in a Check
+var DefaultCheck = Check{
+	Path:     "/",
+	Interval: 5 * time.Second,
+	Timeout:  2 * time.Second,
+	Rise:     1,
+	Fall:     2,
+}
+
+// New
+func New(onChange func(Status)) *Checker {
+	return &Checker{
+		OnChange: onChange,
+		targets:  map[string]*state{},
+	}
+}
+
+// Watch starts checking a target. Targets start out healthy so new containers
+// are not ejected before they are checked the first time. Watching a target
+// again with a different check restarts its checks.
+func (c *Checker) Watch(target string, check Check) {
+	check = check.withDefaults()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if s, ok := c.targets[target]; ok {
+		if s.check == check {
+			return
+		}
+		close(s.done)
+	}
+
+	s := &state{
+		Status: Status{Target: target, Healthy: true},
+		check:  check,
+		client: &http.Client{Timeout: check.Timeout},
+		done:   make(chan struct{}),
+	}
+	// keep what we already know about the target
+	if old, ok := c.targets[target]; ok {
+		s.Status = old.Status
+	}
+	c.targets[target] = s
+
+	go c.run(s)
+}
+
+// Prune stops checking every target that isnt in the keep list
+func (c *Checker) Prune(keep []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	wanted := map[string]bool{}
+	for _, target := range keep {
+		wanted[target] = true
+	}
+
+	for target, s := range c.targets {
+		if !wanted[target] {
+			close(s.done)
+			delete(c.targets, target)
+		}
+	}
+}
+
+// Healthy tells if a target should be getting requests. Targets that arent
+// being checked are always healthy.
+func (c *Checker) Healthy(target string) bool {
+	return c.Status(target).Healthy
+}
+
+// Status
+func (c *Checker) Status(target string) Status {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s, ok := c.targets[target]
+	if !ok {
+		return Status{Target: target, Healthy: true}
+	}
+	return s.Status
+}
+
+// run checks a target until it is no longer watched
+func (c *Checker) run(s *state) {
+	ticker := time.NewTicker(s.check.Interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.probe()
+
+		c.mutex.Lock()
+		select {
+		case <-s.done:
+			c.mutex.Unlock()
+			return
+		default:
+		}
+		changed := s.record(err)
+		status := s.Status
+		c.mutex.Unlock()
+
+		if changed && c.OnChange != nil {
+			c.OnChange(status)
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe makes a single request to the target. Anything but a server error
+// means the app is up, a 404 on the check path is still an app answering.
+func (s *state) probe() error {
+	res, err := s.client.Get(strings.TrimRight(s.Target, "/") + s.check.Path)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("%s responded with %s", s.check.Path, res.Status)
+	}
+	return nil
+}
+
+// record the result of a probe and return true when the target changed
+// between healthy and unhealthy. It expects the caller to hold the mutex.
+func (s *state) record(err error) bool {
+	s.Checked = time.Now()
+
+	if err != nil {
+		s.Error = err.Error()
+		s.rises = 0
+		s.falls++
+		if s.Healthy && s.falls >= s.check.Fall {
+			s.Healthy = false
+			return true
+		}
+		return false
+	}
+
+	s.Error = ""
+	s.falls = 0
+	s.rises++
+	if !s.Healthy && s.rises >= s.check.Rise {
+		s.Healthy = true
+		return true
+	}
+	return false
+}
+
+// withDefaults
+func (check Check) withDefaults() Check {
+	if check.Path == "" {
+		check.Path = DefaultCheck.Path
+	}
+	if !strings.HasPrefix(check.Path, "/") {
+		check.Path = "/" + check.Path
+	}
+	if check.Interval <= 0 {
+		check.Interval = DefaultCheck.Interval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheck.Timeout
+	}
+	if check.Rise < 1 {
+		check.Rise = DefaultCheck.Rise
+	}
+	if check.Fall < 1 {
+		check.Fall = DefaultCheck.Fall
+	}
+	return check
+}
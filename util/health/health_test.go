@@ -0,0 +1,65 @@
+package health_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/util/health"
+)
+
+func TestCheckerEjectsAndRestores(t *testing.T) {
+	var failing int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/health" {
+			t.Errorf("the check went to %s instead of /health", req.URL.Path)
+		}
+		if atomic.LoadInt32(&failing) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	changes := make(chan bool, 10)
+	checker := health.New(func(status health.Status) {
+		changes <- status.Healthy
+	})
+	defer checker.Prune(nil)
+
+	checker.Watch(server.URL, health.Check{Path: "health", Interval: 10 * time.Millisecond, Fall: 2})
+	if !checker.Healthy(server.URL) {
+		t.Error("new targets should start out healthy")
+	}
+
+	atomic.StoreInt32(&failing, 1)
+	select {
+	case healthy := <-changes:
+		if healthy {
+			t.Error("the target should have been ejected")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the failing target was never ejected")
+	}
+	if status := checker.Status(server.URL); status.Error == "" {
+		t.Errorf("the status should say why the target is unhealthy (%+v)", status)
+	}
+
+	atomic.StoreInt32(&failing, 0)
+	select {
+	case healthy := <-changes:
+		if !healthy {
+			t.Error("the target should have been restored")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the target was never restored")
+	}
+}
+
+func TestCheckerUnknownTarget(t *testing.T) {
+	checker := health.New(nil)
+	if !checker.Healthy("http://127.0.0.1:1") {
+		t.Error("targets that arent checked should be healthy")
+	}
+}
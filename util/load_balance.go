@@ -1,38 +1,45 @@
 package util
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/nanobox-io/golang-lvs"
 	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/forward"
 )
 
+// Forwarder does the port forwarding, which one is picked with the
+// NANOBOX_FORWARDER setting
+var Forwarder forward.Forwarder
+
 // make sure the router is being forwarded
 func init() {
-	lvs.DefaultIpvs.Save()
-	AddForward("80", config.IP, config.Ports["router"])
-	AddForward("443", config.IP, config.Ports["router"])
-}
-
-// add a server into the lvs system
-func AddForward(fromPort, toIp, toPort string) error {
-	fromInt, err := strconv.Atoi(fromPort)
+	var err error
+	Forwarder, err = forward.New(config.Forwarder, config.IP)
 	if err != nil {
 		config.Log.Error("error: %s\n", err.Error())
-		return err
+		Forwarder = forward.NewUserspace(config.IP)
 	}
-	err = lvs.DefaultIpvs.AddService(lvs.Service{Host: config.IP, Port: fromInt, Type: "tcp", Persistence: 300})
+	config.Log.Debug("forwarding ports with %T", Forwarder)
+
+	AddForward("tcp", "80", config.IP, config.Ports["router"])
+	AddForward("tcp", "443", config.IP, config.Ports["router_tls"])
+}
+
+// add a server into the forwarder, netType is either tcp or udp
+func AddForward(netType, fromPort, toIp, toPort string) error {
+	if netType != "tcp" && netType != "udp" {
+		return fmt.Errorf("cant forward %s ports", netType)
+	}
+	fromInt, err := strconv.Atoi(fromPort)
 	if err != nil {
 		config.Log.Error("error: %s\n", err.Error())
 		return err
 	}
-	// look up the service instead of using the one we created
-	// this keeps the communication with the lvs package simple
-	service := lvs.DefaultIpvs.FindService("tcp", config.IP, fromInt)
-
 	toInt, _ := strconv.Atoi(toPort)
-	server := lvs.Server{Host: toIp, Port: toInt, Weight: 1, Forwarder: "m"}
-	err = service.AddServer(server)
+
+	err = Forwarder.Add(netType, fromInt, toIp, toInt)
 	if err != nil {
 		config.Log.Error("error: %s\n", err.Error())
 		return err
@@ -40,17 +47,12 @@ func AddForward(fromPort, toIp, toPort string) error {
 	return nil
 }
 
+// RemoveForward removes every tcp and udp service forwarding to ip
 func RemoveForward(ip string) error {
-	for _, service := range lvs.DefaultIpvs.Services {
-		for _, server := range service.Servers {
-			if server.Host == ip {
-				err := lvs.DefaultIpvs.RemoveService(service.Type, service.Host, service.Port)
-				if err != nil {
-					config.Log.Error("error: %s\n", err.Error())
-					return err
-				}
-			}
-		}
+	err := Forwarder.Remove(ip)
+	if err != nil {
+		config.Log.Error("error: %s\n", err.Error())
+		return err
 	}
 	return nil
 
@@ -80,5 +82,5 @@ func RemoveForward(ip string) error {
 }
 
 func ListVips() ([]lvs.Service, error) {
-	return lvs.DefaultIpvs.Services, nil
+	return Forwarder.List(), nil
 }
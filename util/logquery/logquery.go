@@ -0,0 +1,308 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package logquery filters the logs kept in the logtap archive and the ones
+// coming in live.
+package logquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nanobox-io/nanobox-logtap"
+	"github.com/nanobox-io/nanobox-logtap/archive"
+)
+
+// Levels are the names of the logtap priorities
+var Levels = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+
+// DefaultSources are always searched when a query doesnt name its sources
+var DefaultSources = []string{"app", "deploy", "router"}
+
+// jobTag starts every line of job output, it carries the job's id so the
+// output of overlapping jobs can be told apart
+const jobTag = "[job:"
+
+// pageSize is how many messages are read from the archive at a time
+const pageSize = 500
+
+// maxScan stops a query from reading a whole source when few messages match
+const maxScan = 50000
+
+// Filter decides which messages a query returns
+type Filter struct {
+	Sources  []string
+	MinLevel int
+	MaxLevel int
+	Since    time.Time
+	Until    time.Time
+	Contains string
+	Regex    *regexp.Regexp
+	Job      string
+	Limit    int
+}
+
+var (
+	sources    = map[string]bool{}
+	sourcesTex = sync.Mutex{}
+)
+
+// TagJob marks a line of output with the job it came from
+func TagJob(id, content string) string {
+	if id == "" {
+		return content
+	}
+	return jobTag + id + "] " + content
+}
+
+// JobOf returns the id of the job a message came from, or "" when it isnt
+// job output
+func JobOf(msg logtap.Message) string {
+	if !strings.HasPrefix(msg.Content, jobTag) {
+		return ""
+	}
+	end := strings.Index(msg.Content, "] ")
+	if end < 0 {
+		return ""
+	}
+	return msg.Content[len(jobTag):end]
+}
+
+// Track is a logtap drain that remembers every source that logged something
+// so queries without sources can search all of them
+func Track(log logtap.Logger, msg logtap.Message) {
+	sourcesTex.Lock()
+	defer sourcesTex.Unlock()
+
+	sources[msg.Type] = true
+}
+
+// Sources lists the default and the tracked sources
+func Sources() []string {
+	sourcesTex.Lock()
+	defer sourcesTex.Unlock()
+
+	rtn := append([]string{}, DefaultSources...)
+	for source := range sources {
+		if !contains(rtn, source) {
+			rtn = append(rtn, source)
+		}
+	}
+	sort.Strings(rtn)
+	return rtn
+}
+
+// ParseFilter reads a filter from query parameters:
+//
+//   source:    comma separated sources, eg app,deploy,web1, or * for every
+//              source. app when it isnt given.
+//   kind:      the old name of source
+//   level:     the lowest level to return, a name or a number
+//   max_level: the highest level to return
+//   since:     a RFC3339 time or a duration ago, eg 10m
+//   until:     a RFC3339 time or a duration ago
+//   contains:  a substring the message has to contain
+//   regex:     a regular expression the message has to match
+//   limit:     how many of the latest matches to return, 100 by default
+func ParseFilter(values url.Values) (Filter, error) {
+	filter := Filter{MinLevel: 0, MaxLevel: len(Levels) - 1, Limit: 100}
+	var err error
+
+	source := values.Get("source")
+	if source == "" {
+		source = values.Get("kind")
+	}
+	switch source {
+	case "":
+		filter.Sources = []string{"app"}
+	case "*":
+		// no sources searches all of them
+	default:
+		for _, source := range strings.Split(source, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				filter.Sources = append(filter.Sources, source)
+			}
+		}
+	}
+
+	if level := values.Get("level"); level != "" {
+		if filter.MinLevel, err = ParseLevel(level); err != nil {
+			return filter, err
+		}
+	}
+	if level := values.Get("max_level"); level != "" {
+		if filter.MaxLevel, err = ParseLevel(level); err != nil {
+			return filter, err
+		}
+	}
+	if filter.MinLevel > filter.MaxLevel {
+		return filter, fmt.Errorf("level can't be above max_level")
+	}
+
+	if since := values.Get("since"); since != "" {
+		if filter.Since, err = parseTime(since); err != nil {
+			return filter, err
+		}
+	}
+	if until := values.Get("until"); until != "" {
+		if filter.Until, err = parseTime(until); err != nil {
+			return filter, err
+		}
+	}
+
+	filter.Contains = values.Get("contains")
+	if expr := values.Get("regex"); expr != "" {
+		if filter.Regex, err = regexp.Compile(expr); err != nil {
+			return filter, fmt.Errorf("invalid regex: %s", err.Error())
+		}
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 1 {
+			return filter, fmt.Errorf("limit must be a number above 0")
+		}
+	}
+
+	return filter, nil
+}
+
+// ParseLevel takes a level name or number
+func ParseLevel(level string) (int, error) {
+	for i, name := range Levels {
+		if strings.EqualFold(level, name) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(level); err == nil && i >= 0 && i < len(Levels) {
+		return i, nil
+	}
+	return 0, fmt.Errorf("%q is not a log level, use one of %s", level, strings.Join(Levels, ", "))
+}
+
+// parseTime takes a RFC3339 time or a duration before now
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a RFC3339 time or a duration", value)
+}
+
+// Match tells if a message passes the filter. A filter without sources
+// matches every source.
+func (f Filter) Match(msg logtap.Message) bool {
+	switch {
+	case len(f.Sources) > 0 && !contains(f.Sources, msg.Type):
+		return false
+	case msg.Priority < f.MinLevel || msg.Priority > f.MaxLevel:
+		return false
+	case !f.Since.IsZero() && msg.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && msg.Time.After(f.Until):
+		return false
+	case f.Contains != "" && !strings.Contains(msg.Content, f.Contains):
+		return false
+	case f.Regex != nil && !f.Regex.MatchString(msg.Content):
+		return false
+	case f.Job != "" && JobOf(msg) != f.Job:
+		return false
+	}
+	return true
+}
+
+// Query returns the latest messages in the archive that pass the filter,
+// oldest first
+func Query(a archive.Archive, f Filter) ([]logtap.Message, error) {
+	names := f.Sources
+	if len(names) == 0 {
+		names = Sources()
+	}
+
+	rtn := []logtap.Message{}
+	for _, name := range names {
+		matches, err := querySource(a, name, f)
+		if err != nil {
+			return nil, err
+		}
+		rtn = append(rtn, matches...)
+	}
+
+	sort.Stable(byTime(rtn))
+	if len(rtn) > f.Limit {
+		rtn = rtn[len(rtn)-f.Limit:]
+	}
+	return rtn, nil
+}
+
+// querySource pages back through a single source until it has enough matches
+// or reaches messages older than the filter wants
+func querySource(a archive.Archive, name string, f Filter) ([]logtap.Message, error) {
+	rtn := []logtap.Message{}
+	for offset := uint64(0); offset < maxScan; offset += pageSize {
+		page, err := a.Slice(name, offset, pageSize, f.MinLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		older := false
+		for _, msg := range page {
+			if !f.Since.IsZero() && msg.Time.Before(f.Since) {
+				older = true
+			}
+			if f.Match(msg) {
+				rtn = append(rtn, msg)
+			}
+		}
+
+		if len(page) < pageSize || older || len(rtn) >= f.Limit {
+			break
+		}
+	}
+	return rtn, nil
+}
+
+// Format writes a message as a json line or as plain text
+func Format(msg logtap.Message, format string) string {
+	if format == "text" {
+		level := "unknown"
+		if msg.Priority >= 0 && msg.Priority < len(Levels) {
+			level = Levels[msg.Priority]
+		}
+		return fmt.Sprintf("%s [%s] %s %s\n", msg.Time.Format(time.RFC3339), msg.Type, strings.ToUpper(level), strings.TrimRight(msg.Content, "\n"))
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+	return string(b) + "\n"
+}
+
+// contains
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if entry == item {
+			return true
+		}
+	}
+	return false
+}
+
+// byTime
+type byTime []logtap.Message
+
+func (m byTime) Len() int           { return len(m) }
+func (m byTime) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m byTime) Less(i, j int) bool { return m[i].Time.Before(m[j].Time) }
@@ -0,0 +1,146 @@
+package logquery_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/util/logquery"
+)
+
+// fakeArchive returns the newest messages first like the archive
+type fakeArchive map[string][]logtap.Message
+
+func (a fakeArchive) Slice(name string, offset, limit uint64, level int) ([]logtap.Message, error) {
+	rtn := []logtap.Message{}
+	msgs := a[name]
+	for i := len(msgs) - 1; i >= 0 && uint64(len(rtn)) < limit; i-- {
+		if msgs[i].Priority < level {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		rtn = append(rtn, msgs[i])
+	}
+	return rtn, nil
+}
+
+func TestParseFilter(t *testing.T) {
+	filter, err := logquery.ParseFilter(url.Values{
+		"source":    {"app, web1"},
+		"level":     {"warn"},
+		"max_level": {"4"},
+		"since":     {"10m"},
+		"regex":     {"^GET"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filter.Sources) != 2 || filter.Sources[1] != "web1" {
+		t.Errorf("the sources were not parsed (%v)", filter.Sources)
+	}
+	if filter.MinLevel != 3 || filter.MaxLevel != 4 {
+		t.Errorf("the levels should be 3-4 not %d-%d", filter.MinLevel, filter.MaxLevel)
+	}
+	if time.Since(filter.Since) < 9*time.Minute {
+		t.Errorf("since should be 10 minutes ago not %s", filter.Since)
+	}
+
+	filter, _ = logquery.ParseFilter(url.Values{})
+	if len(filter.Sources) != 1 || filter.Sources[0] != "app" {
+		t.Errorf("the app logs should be returned by default (%v)", filter.Sources)
+	}
+	filter, _ = logquery.ParseFilter(url.Values{"kind": {"deploy"}})
+	if len(filter.Sources) != 1 || filter.Sources[0] != "deploy" {
+		t.Errorf("kind should still pick the source (%v)", filter.Sources)
+	}
+	filter, _ = logquery.ParseFilter(url.Values{"source": {"*"}})
+	if len(filter.Sources) != 0 {
+		t.Errorf("* should search every source (%v)", filter.Sources)
+	}
+
+	invalid := []url.Values{
+		{"level": {"loud"}},
+		{"level": {"error"}, "max_level": {"info"}},
+		{"since": {"yesterday"}},
+		{"regex": {"("}},
+		{"limit": {"0"}},
+	}
+	for _, values := range invalid {
+		if _, err := logquery.ParseFilter(values); err == nil {
+			t.Errorf("%v should not be a valid filter", values)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	now := time.Now()
+	a := fakeArchive{
+		"app": {
+			{Type: "app", Time: now.Add(-time.Hour), Priority: 2, Content: "GET /old"},
+			{Type: "app", Time: now.Add(-3 * time.Minute), Priority: 2, Content: "GET /"},
+			{Type: "app", Time: now.Add(-time.Minute), Priority: 4, Content: "POST / failed"},
+		},
+		"web1": {
+			{Type: "web1", Time: now.Add(-2 * time.Minute), Priority: 2, Content: "GET /web"},
+		},
+	}
+
+	filter, _ := logquery.ParseFilter(url.Values{"source": {"app,web1"}, "since": {"10m"}, "contains": {"GET"}})
+	msgs, err := logquery.Query(a, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Content != "GET /" || msgs[1].Content != "GET /web" {
+		t.Errorf("the query returned the wrong messages (%+v)", msgs)
+	}
+
+	filter, _ = logquery.ParseFilter(url.Values{"source": {"app"}, "level": {"error"}})
+	msgs, _ = logquery.Query(a, filter)
+	if len(msgs) != 1 || msgs[0].Priority != 4 {
+		t.Errorf("only the error should have matched (%+v)", msgs)
+	}
+
+	filter, _ = logquery.ParseFilter(url.Values{"source": {"app"}, "limit": {"1"}})
+	msgs, _ = logquery.Query(a, filter)
+	if len(msgs) != 1 || msgs[0].Content != "POST / failed" {
+		t.Errorf("the limit should keep the latest message (%+v)", msgs)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	logquery.Track(nil, logtap.Message{Type: "web1"})
+
+	sources := logquery.Sources()
+	found := map[string]bool{}
+	for _, source := range sources {
+		found[source] = true
+	}
+	if !found["web1"] || !found["deploy"] {
+		t.Errorf("the tracked and default sources should be listed (%v)", sources)
+	}
+}
+
+func TestMatchJob(t *testing.T) {
+	line := logtap.Message{Type: "deploy", Priority: 2, Content: logquery.TagJob("abc", "building")}
+	if id := logquery.JobOf(line); id != "abc" {
+		t.Errorf("the line should be from job abc not %q", id)
+	}
+
+	filter := logquery.Filter{Sources: []string{"deploy"}, MaxLevel: 5, Job: "abc"}
+	if !filter.Match(line) {
+		t.Errorf("the job's own line should match")
+	}
+
+	filter.Job = "xyz"
+	if filter.Match(line) {
+		t.Errorf("another job's line should not match")
+	}
+	if filter.Match(logtap.Message{Type: "deploy", Priority: 2, Content: "building"}) {
+		t.Errorf("an untagged line should not match a job")
+	}
+}
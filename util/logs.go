@@ -7,39 +7,129 @@
 package util
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/nanobox-io/nanobox-router"
 	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/logquery"
+	"github.com/nanobox-io/nanobox-server/util/pages"
 )
 
+// JobLog publishes deploy logs for a job. Every line goes to the deploy source
+// tagged with the job's id so its output can be pulled up on its own. A
+// JobLog without an ID logs untagged lines.
+type JobLog struct {
+	ID string
+}
+
+// Debug
+func (l JobLog) Debug(f string, v ...interface{}) {
+	l.publish(1, fmt.Sprintf(f, v...))
+}
+
+// Info
+func (l JobLog) Info(f string, v ...interface{}) {
+	l.publish(2, fmt.Sprintf(f, v...))
+}
+
+// Warn
+func (l JobLog) Warn(f string, v ...interface{}) {
+	l.publish(3, fmt.Sprintf(f, v...))
+}
+
+// Error
+func (l JobLog) Error(f string, v ...interface{}) {
+	l.publish(4, fmt.Sprintf(f, v...))
+}
+
+// Fatal
+func (l JobLog) Fatal(f string, v ...interface{}) {
+	l.publish(5, fmt.Sprintf(f, v...))
+}
+
+// HandleError logs the error and puts up the failed deploy page
+func (l JobLog) HandleError(msg string) {
+	l.Debug(msg)
+	router.ErrorHandler = pages.Page{Name: pages.Failed}
+}
+
+// Writer returns a writer that publishes every line written to it at the
+// given priority. Flush it once the writing is done.
+func (l JobLog) Writer(priority int) *LineWriter {
+	return &LineWriter{Publish: func(line string) { l.publish(priority, line) }}
+}
+
+// publish
+func (l JobLog) publish(priority int, content string) {
+	config.Logtap.Publish("deploy", priority, logquery.TagJob(l.ID, content))
+}
+
+// LineWriter hands every line written to it to Publish
+type LineWriter struct {
+	Publish func(line string)
+
+	buf []byte
+}
+
+// Write
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.publish(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush publishes whatever is left without a newline
+func (w *LineWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.publish(w.buf)
+		w.buf = nil
+	}
+}
+
+// publish
+func (w *LineWriter) publish(line []byte) {
+	// tty output ends lines with \r\n
+	line = bytes.TrimRight(line, "\r")
+	if len(line) == 0 {
+		return
+	}
+	w.Publish(string(line))
+}
+
 // LogDebug
 func LogDebug(f string, v ...interface{}) {
-	config.Logtap.Publish("deploy", 1, fmt.Sprintf(f, v...))
+	JobLog{}.Debug(f, v...)
 }
 
 // LogInfo
 func LogInfo(f string, v ...interface{}) {
-	config.Logtap.Publish("deploy", 2, fmt.Sprintf(f, v...))
+	JobLog{}.Info(f, v...)
 }
 
 // LogWarn
 func LogWarn(f string, v ...interface{}) {
-	config.Logtap.Publish("deploy", 3, fmt.Sprintf(f, v...))
+	JobLog{}.Warn(f, v...)
 }
 
 // LogError
 func LogError(f string, v ...interface{}) {
-	config.Logtap.Publish("deploy", 4, fmt.Sprintf(f, v...))
+	JobLog{}.Error(f, v...)
 }
 
 // LogFatal
 func LogFatal(f string, v ...interface{}) {
-	config.Logtap.Publish("deploy", 5, fmt.Sprintf(f, v...))
+	JobLog{}.Fatal(f, v...)
 }
 
 // HandleError
 func HandleError(msg string) {
-	LogDebug(msg)
-	router.ErrorHandler = router.FailedDeploy{}
+	JobLog{}.HandleError(msg)
 }
@@ -0,0 +1,221 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package pages renders the pages the router shows while the app can't take
+// requests. Apps can replace the built in pages with their own html templates.
+package pages
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/nanobox-io/nanobox-logtap"
+	"github.com/nanobox-io/nanobox-router"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util/proxy"
+)
+
+// the pages an app can replace
+const (
+	Deploying = "deploying"
+	Failed    = "failed"
+	NoRoute   = "no_route"
+)
+
+// Names lists every page an app can replace
+var Names = []string{Deploying, Failed, NoRoute}
+
+// LogLines is how many of the latest deploy log lines a template gets
+var LogLines = 50
+
+// structs
+type (
+
+	// Page is a router error handler that renders the app's template for a
+	// page, or the built in one when the app doesnt have its own
+	Page struct {
+		Name string
+	}
+
+	// Data is what the templates are rendered with
+	Data struct {
+		App  string
+		Page string
+		Job  string
+		Host string
+		Path string
+		Logs []string
+	}
+)
+
+var (
+	templates = map[string]*template.Template{}
+	job       string
+	logs      []string
+	pagesTex  = sync.Mutex{}
+)
+
+// Load replaces the templates with the ones from the app. A page in the pages
+// node of the Boxfile is either a path in the app dir, or the html itself
+// under <page>_html:
+//
+//   pages:
+//     deploying: pages/deploying.html
+//     failed_html: "<h1>{{.App}} failed to deploy</h1>"
+//
+// Pages that arent in the Boxfile are looked for in the app's .nanobox/pages
+// dir as <page>.html.
+func Load(appDir string, box map[string]string) error {
+	loaded := map[string]*template.Template{}
+
+	var firstErr error
+	for _, name := range Names {
+		text, err := source(appDir, name, box)
+		if err == nil && text != "" {
+			var tmpl *template.Template
+			tmpl, err = template.New(name).Parse(text)
+			if err == nil {
+				loaded[name] = tmpl
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	pagesTex.Lock()
+	templates = loaded
+	pagesTex.Unlock()
+
+	return firstErr
+}
+
+// source finds the template text for a page
+func source(appDir, name string, box map[string]string) (string, error) {
+	// html in the Boxfile
+	if html := box[name+"_html"]; html != "" {
+		return html, nil
+	}
+
+	value := box[name]
+	path := filepath.Join(appDir, ".nanobox", "pages", name+".html")
+	if value != "" {
+		path = filepath.Join(appDir, value)
+	}
+	if !inside(appDir, path) {
+		return "", fmt.Errorf("the %s page %q is outside of the app", name, value)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && value == "" {
+		return "", nil
+	}
+	return string(b), err
+}
+
+// inside tells if a path is in the app dir, after following any symlinks
+func inside(appDir, path string) bool {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	if real, err := filepath.EvalSymlinks(appDir); err == nil {
+		appDir = real
+	}
+
+	rel, err := filepath.Rel(appDir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Custom tells if the app has its own template for a page
+func Custom(name string) bool {
+	pagesTex.Lock()
+	defer pagesTex.Unlock()
+
+	_, ok := templates[name]
+	return ok
+}
+
+// SetJob starts collecting the deploy logs for a new job
+func SetJob(id string) {
+	pagesTex.Lock()
+	defer pagesTex.Unlock()
+
+	job = id
+	logs = []string{}
+}
+
+// Drain keeps the latest deploy log lines for the templates
+func Drain(log logtap.Logger, msg logtap.Message) {
+	if msg.Type != "deploy" {
+		return
+	}
+
+	pagesTex.Lock()
+	defer pagesTex.Unlock()
+
+	logs = append(logs, strings.TrimRight(msg.Content, "\n"))
+	if len(logs) > LogLines {
+		logs = logs[len(logs)-LogLines:]
+	}
+}
+
+// ServeHTTP
+func (p Page) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	proxy.ServePage(p.Name, p.serve, rw, req)
+}
+
+// serve renders the app's template, or the router's own page when the app
+// doesn't have one
+func (p Page) serve(rw http.ResponseWriter, req *http.Request) {
+	pagesTex.Lock()
+	tmpl, ok := templates[p.Name]
+	data := Data{
+		App:  config.App(),
+		Page: p.Name,
+		Job:  job,
+		Host: req.Host,
+		Path: req.URL.Path,
+		Logs: append([]string{}, logs...),
+	}
+	pagesTex.Unlock()
+
+	if !ok {
+		p.builtin().ServeHTTP(rw, req)
+		return
+	}
+
+	status := http.StatusServiceUnavailable
+	if p.Name == NoRoute {
+		status = http.StatusNotFound
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(status)
+	if err := tmpl.Execute(rw, data); err != nil {
+		config.Log.Error("[nanobox/pages] Unable to render the %s page: %s", p.Name, err.Error())
+	}
+}
+
+// builtin is the router's own page
+func (p Page) builtin() http.Handler {
+	switch p.Name {
+	case Deploying:
+		return router.DeployInProgress{}
+	case Failed:
+		return router.FailedDeploy{}
+	}
+	return router.NoRoutes{}
+}
@@ -0,0 +1,70 @@
+package pages_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nanobox-io/nanobox-logtap"
+
+	"github.com/nanobox-io/nanobox-server/util/pages"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, ".nanobox", "pages"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".nanobox", "pages", "failed.html"), []byte("failed {{.Job}}"), 0644)
+
+	err = pages.Load(dir, map[string]string{
+		"deploying_html": "<p>{{.Job}}: {{range .Logs}}{{.}};{{end}}</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pages.Custom(pages.Deploying) || !pages.Custom(pages.Failed) || pages.Custom(pages.NoRoute) {
+		t.Error("the wrong pages were loaded")
+	}
+
+	err = pages.Load(dir, map[string]string{"no_route": "missing.html"})
+	if err == nil {
+		t.Error("a missing page from the Boxfile should be an error")
+	}
+
+	ioutil.WriteFile(filepath.Join(filepath.Dir(dir), "outside.html"), []byte("secret"), 0644)
+	defer os.Remove(filepath.Join(filepath.Dir(dir), "outside.html"))
+	err = pages.Load(dir, map[string]string{"no_route": "../outside.html"})
+	if err == nil || pages.Custom(pages.NoRoute) {
+		t.Error("a page outside of the app should be an error")
+	}
+}
+
+func TestPageRender(t *testing.T) {
+	if err := pages.Load("", map[string]string{"deploying_html": "<p>{{.Job}}: {{range .Logs}}{{.}};{{end}}</p>"}); err != nil {
+		t.Fatal(err)
+	}
+
+	pages.SetJob("abc")
+	pages.Drain(nil, logtap.Message{Type: "deploy", Content: "one\n"})
+	pages.Drain(nil, logtap.Message{Type: "app", Content: "not a deploy line"})
+	pages.Drain(nil, logtap.Message{Type: "deploy", Content: "two\n"})
+
+	req, _ := http.NewRequest("GET", "http://app.dev/", nil)
+	rw := httptest.NewRecorder()
+	pages.Page{Name: pages.Deploying}.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Errorf("the deploying page should be a 503 not a %d", rw.Code)
+	}
+	if body := rw.Body.String(); !strings.Contains(body, "abc: one;two;") {
+		t.Errorf("the page is missing the job or the logs: %s", body)
+	}
+}
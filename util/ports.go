@@ -0,0 +1,187 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// PortAllocation is the host port a service is reachable on
+type PortAllocation struct {
+	UID    string `json:"uid"`
+	Type   string `json:"type"`
+	Port   int    `json:"port"`
+	Host   string `json:"host"`
+	ToPort int    `json:"to_port"`
+	Active bool   `json:"active"`
+}
+
+// portRange is how far past the port a service asks for we look for a free one
+const portRange = 100
+
+var portAllocations map[string]PortAllocation
+
+var portsTex = sync.Mutex{}
+
+// AllocatePort finds the host port for a service. A service keeps the port it
+// had before so its tunnel doesnt move between deploys, otherwise it gets the
+// first free port starting at the one it asked for. A port is free when no
+// other service has it and nothing else is forwarding it.
+func AllocatePort(uid, netType string, want int, toIp string, toPort int) (int, error) {
+	if toPort < 1 || toPort > 65535 {
+		return 0, fmt.Errorf("%s does not have a valid port to forward to (%d)", uid, toPort)
+	}
+	if want < 1 || want > 65535 {
+		return 0, fmt.Errorf("%s asked for an invalid port (%d)", uid, want)
+	}
+
+	portsTex.Lock()
+	defer portsTex.Unlock()
+
+	allocations := loadPorts()
+
+	if existing, ok := allocations[uid]; ok && existing.Type == netType && portFree(allocations, uid, netType, existing.Port, toIp) {
+		want = existing.Port
+	}
+
+	for port := want; port < want+portRange && port <= 65535; port++ {
+		if !portFree(allocations, uid, netType, port, toIp) {
+			continue
+		}
+
+		allocations[uid] = PortAllocation{UID: uid, Type: netType, Port: port, Host: toIp, ToPort: toPort}
+		if err := savePorts(allocations); err != nil {
+			config.Log.Error("[nanobox/util] Unable to save the port allocations: %s", err.Error())
+		}
+		return port, nil
+	}
+	return 0, fmt.Errorf("there are no free %s ports between %d and %d", netType, want, want+portRange-1)
+}
+
+// ReleasePort frees the port of a service that is gone for good
+func ReleasePort(uid string) {
+	portsTex.Lock()
+	defer portsTex.Unlock()
+
+	allocations := loadPorts()
+	if _, ok := allocations[uid]; !ok {
+		return
+	}
+	delete(allocations, uid)
+	if err := savePorts(allocations); err != nil {
+		config.Log.Error("[nanobox/util] Unable to save the port allocations: %s", err.Error())
+	}
+}
+
+// PortAllocations lists the allocations by port and tells which of them are
+// being forwarded right now
+func PortAllocations() []PortAllocation {
+	portsTex.Lock()
+	defer portsTex.Unlock()
+
+	rtn := []PortAllocation{}
+	for _, allocation := range loadPorts() {
+		allocation.Active = Forwarding(allocation.Type, allocation.Port, allocation.Host)
+		rtn = append(rtn, allocation)
+	}
+	sort.Sort(byPort(rtn))
+	return rtn
+}
+
+// Forwarding tells if port is already being forwarded to ip
+func Forwarding(netType string, port int, ip string) bool {
+	for _, service := range Forwarder.List() {
+		if service.Type != netType || service.Port != port {
+			continue
+		}
+		for _, server := range service.Servers {
+			if server.Host == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// portFree expects the caller to hold the portsTex lock
+func portFree(allocations map[string]PortAllocation, uid, netType string, port int, toIp string) bool {
+	// the router owns these
+	if netType == "tcp" && (port == 80 || port == 443) {
+		return false
+	}
+	for _, reserved := range config.Ports {
+		if strconv.Itoa(port) == strings.TrimPrefix(reserved, ":") {
+			return false
+		}
+	}
+
+	for other, allocation := range allocations {
+		if other != uid && allocation.Type == netType && allocation.Port == port {
+			return false
+		}
+	}
+
+	// something we dont know about is forwarding the port
+	for _, service := range Forwarder.List() {
+		if service.Type != netType || service.Port != port {
+			continue
+		}
+		for _, server := range service.Servers {
+			if server.Host != toIp {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// loadPorts reads the allocations the first time they are needed. It expects
+// the caller to hold the portsTex lock.
+func loadPorts() map[string]PortAllocation {
+	if portAllocations != nil {
+		return portAllocations
+	}
+
+	portAllocations = map[string]PortAllocation{}
+	b, err := ioutil.ReadFile(config.PortRegistry)
+	if err != nil {
+		return portAllocations
+	}
+	if err := json.Unmarshal(b, &portAllocations); err != nil {
+		config.Log.Error("[nanobox/util] Unable to read the port allocations: %s", err.Error())
+	}
+	return portAllocations
+}
+
+// savePorts expects the caller to hold the portsTex lock
+func savePorts(allocations map[string]PortAllocation) error {
+	b, err := json.Marshal(allocations)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(config.PortRegistry), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(config.PortRegistry, b, 0644)
+}
+
+// byPort
+type byPort []PortAllocation
+
+func (p byPort) Len() int           { return len(p) }
+func (p byPort) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPort) Less(i, j int) bool { return p[i].Port < p[j].Port }
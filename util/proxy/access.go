@@ -0,0 +1,132 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/config"
+)
+
+// Entry is an access log entry, it is published to logtap as json under the
+// "router" source
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Route    string    `json:"route"`
+	Method   string    `json:"method"`
+	Host     string    `json:"host"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Upstream string    `json:"upstream"`
+	Latency  float64   `json:"latency_ms"`
+	Bytes    int       `json:"bytes"`
+}
+
+// AccessLog publishes an entry. It can be replaced to send the entries
+// somewhere else.
+var AccessLog = func(entry Entry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	// server errors are logged as errors so they stand out
+	priority := 2
+	if entry.Status >= 500 {
+		priority = 4
+	}
+	config.Logtap.Publish("router", priority, string(b))
+}
+
+// recorder keeps the status and size of a response for the access log
+type recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Flush
+func (r *recorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over for protocol upgrades (websockets), the
+// request is logged as switching protocols
+func (r *recorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, buf, err
+}
+
+// Unwrap returns the original response writer
+func (r *recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// ServePage answers a request with one of the router's own pages (no route,
+// deploying, failed...) and writes an access log entry for it under the
+// page's name
+func ServePage(page string, handler http.HandlerFunc, rw http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	rec := &recorder{ResponseWriter: rw}
+	handler(rec, req)
+	logRequest(page, rec, req.Method, req.Host, req.URL.Path, "", start)
+}
+
+// logRequest writes the access log entry for a finished request
+func (p *Proxy) logRequest(rec *recorder, method, host, path, upstream string, start time.Time) {
+	logRequest(p.ID, rec, method, host, path, upstream, start)
+}
+
+// logRequest writes an access log entry for the route
+func logRequest(route string, rec *recorder, method, host, path, upstream string, start time.Time) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	AccessLog(Entry{
+		Time:     start,
+		Route:    route,
+		Method:   method,
+		Host:     host,
+		Path:     path,
+		Status:   rec.status,
+		Upstream: upstream,
+		Latency:  float64(time.Since(start)) / float64(time.Millisecond),
+		Bytes:    rec.bytes,
+	})
+}
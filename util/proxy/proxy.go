@@ -0,0 +1,230 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package proxy runs small local http proxies that sit between nanobox-router
+// and the app containers. The router matches the request and hands it to the
+// route's proxy, the proxy rewrites it, picks the target and writes the access
+// log.
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// structs
+type (
+
+	// Target is somewhere a route sends requests to. A target with a weight
+	// of 3 gets three times the requests of a target with a weight of 1.
+	Target struct {
+		URL    string
+		Weight int
+	}
+
+	//
+	Proxy struct {
+		ID           string
+		StripPrefix  string
+		StickyCookie string
+
+		listener net.Listener
+		mutex    sync.RWMutex
+		targets  map[string]*target
+		rotation []*target
+		next     uint64
+	}
+
+	// target
+	target struct {
+		Target
+		key   string
+		url   *url.URL
+		proxy *httputil.ReverseProxy
+	}
+)
+
+var proxies = map[string]*Proxy{}
+
+var proxiesTex = sync.Mutex{}
+
+// Ensure returns the proxy for a route, starting it the first time it is
+// asked for
+func Ensure(id string) (*Proxy, error) {
+	proxiesTex.Lock()
+	defer proxiesTex.Unlock()
+
+	if p, ok := proxies[id]; ok {
+		return p, nil
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		ID:       id,
+		listener: listener,
+		targets:  map[string]*target{},
+	}
+	go http.Serve(listener, p)
+
+	proxies[id] = p
+	return p, nil
+}
+
+// Prune stops every proxy that isnt in the keep list
+func Prune(keep []string) {
+	proxiesTex.Lock()
+	defer proxiesTex.Unlock()
+
+	wanted := map[string]bool{}
+	for _, id := range keep {
+		wanted[id] = true
+	}
+
+	for id, p := range proxies {
+		if !wanted[id] {
+			p.listener.Close()
+			delete(proxies, id)
+		}
+	}
+}
+
+// URL is the address the router should send this route's requests to
+func (p *Proxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+// Update replaces the targets and rewrite settings. An empty stickyCookie
+// turns stickiness off.
+func (p *Proxy) Update(targets []Target, stripPrefix, stickyCookie string) error {
+	newTargets := map[string]*target{}
+	rotation := []*target{}
+
+	for _, t := range targets {
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			return err
+		}
+		if t.Weight < 1 {
+			t.Weight = 1
+		}
+
+		newTarget := &target{
+			Target: t,
+			key:    targetKey(t.URL),
+			url:    u,
+			proxy:  httputil.NewSingleHostReverseProxy(u),
+		}
+		newTargets[newTarget.key] = newTarget
+
+		// weights are done by putting a target in the rotation more than once
+		for i := 0; i < t.Weight; i++ {
+			rotation = append(rotation, newTarget)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.targets = newTargets
+	p.rotation = rotation
+	p.StripPrefix = strings.TrimRight(stripPrefix, "/")
+	p.StickyCookie = stickyCookie
+	return nil
+}
+
+// ServeHTTP
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	start := time.Now()
+	rw := &recorder{ResponseWriter: w}
+	method, host, path := req.Method, req.Host, req.URL.Path
+
+	p.mutex.RLock()
+	prefix := p.StripPrefix
+	sticky := p.StickyCookie
+	p.mutex.RUnlock()
+
+	target, stuck := p.pick(req, sticky)
+	if target == nil {
+		rw.WriteHeader(http.StatusBadGateway)
+		fmt.Fprintf(rw, "no targets for route %s\n", p.ID)
+		p.logRequest(rw, method, host, path, "", start)
+		return
+	}
+	defer p.logRequest(rw, method, host, path, target.URL, start)
+
+	// remember the target so the session keeps going to the same place
+	if sticky != "" && !stuck {
+		http.SetCookie(rw, &http.Cookie{Name: sticky, Value: target.key, Path: "/", HttpOnly: true})
+	}
+
+	if stripped, ok := strip(prefix, req.URL.Path); ok {
+		req.URL.Path = stripped
+		// the escaped path has to say the same thing as the path
+		if raw, ok := strip(prefix, req.URL.RawPath); ok {
+			req.URL.RawPath = raw
+		} else {
+			req.URL.RawPath = ""
+		}
+		req.Header.Set("X-Forwarded-Prefix", prefix)
+	}
+
+	target.proxy.ServeHTTP(rw, req)
+}
+
+// strip removes the prefix from a path. The prefix only matches whole path
+// segments, /api strips /api and /api/x but leaves /apiv2 alone.
+func strip(prefix, path string) (string, bool) {
+	if prefix == "" || (path != prefix && !strings.HasPrefix(path, prefix+"/")) {
+		return path, false
+	}
+	path = strings.TrimPrefix(path, prefix)
+	if path == "" {
+		path = "/"
+	}
+	return path, true
+}
+
+// pick the target for a request. When the request carries a sticky cookie for
+// a target we still have it goes there, otherwise the next target in the
+// weighted rotation is used.
+func (p *Proxy) pick(req *http.Request, sticky string) (*target, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if sticky != "" {
+		if cookie, err := req.Cookie(sticky); err == nil {
+			if t, ok := p.targets[cookie.Value]; ok {
+				return t, true
+			}
+		}
+	}
+
+	if len(p.rotation) == 0 {
+		return nil, false
+	}
+	n := atomic.AddUint64(&p.next, 1)
+	return p.rotation[n%uint64(len(p.rotation))], false
+}
+
+// targetKey is a short stable name for a target that is safe to put in a
+// cookie without giving away the container address
+func targetKey(u string) string {
+	h := fnv.New32a()
+	h.Write([]byte(u))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -0,0 +1,47 @@
+package proxy_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nanobox-io/nanobox-server/util/proxy"
+)
+
+func TestStripPrefix(t *testing.T) {
+	proxy.AccessLog = func(entry proxy.Entry) {}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.EscapedPath()))
+	}))
+	defer backend.Close()
+
+	p, err := proxy.Ensure("strip-prefix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Prune(nil)
+	if err := p.Update([]proxy.Target{{URL: backend.URL}}, "/api/", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := map[string]string{
+		"/api":        "/",
+		"/api/users":  "/users",
+		"/apiv2/x":    "/apiv2/x",
+		"/api/a%2Fb":  "/a%2Fb",
+		"/other/api/": "/other/api/",
+	}
+	for path, want := range paths {
+		res, err := http.Get(p.URL() + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if string(body) != want {
+			t.Errorf("%s should reach the target as %s not %s", path, want, body)
+		}
+	}
+}
@@ -10,19 +10,31 @@ package script
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/nanobox-io/nanobox-golang-stylish"
 	"github.com/nanobox-io/nanobox-server/util"
 	"github.com/nanobox-io/nanobox-server/util/docker"
 )
 
+// quiet are the scripts whose output is data for the caller, eg the
+// environment hook prints credentials, so only their errors are streamed
+var quiet = map[string]bool{
+	"environment":     true,
+	"default-boxfile": true,
+}
+
 // Exec executes a script using docker
 // It is not in the docker package becuase it isnt a function of docker
 // but more a function of our system
 // it makes more sense to do script.Exec then docker.ExecScript
 // it is alos a var instead of a package function so we can swap it out for a
 // mock function in tests.
-var Exec = func(name, container string, payload map[string]interface{}) ([]byte, error) {
+// job is the id of the job running the script, its output and failures are
+// logged with the job's output. Scripts run outside of a job pass an empty job.
+// The output is streamed into the logs line by line as the script runs and is
+// also returned in full.
+var Exec = func(job, name, container string, payload map[string]interface{}) ([]byte, error) {
 	if payload == nil {
 		payload = map[string]interface{}{}
 	}
@@ -32,10 +44,23 @@ var Exec = func(name, container string, payload map[string]interface{}) ([]byte,
 		return nil, err
 	}
 
-	out, err := docker.ExecInContainer(container, "/opt/bin/"+name, string(b))
+	log := util.JobLog{ID: job}
+
+	lines, stderr := log.Writer(2), log.Writer(3)
+	var stdout io.Writer
+	if !quiet[name] {
+		stdout = lines
+	}
+
+	out, err := docker.StreamExecInContainer(container, stdout, stderr, "/opt/bin/"+name, string(b))
+	lines.Flush()
+	stderr.Flush()
+
 	if err != nil {
-		util.LogDebug("Failed script output(%s): \n %s", name, out)
-		util.HandleError(stylish.Error(fmt.Sprintf("Failed to run %s script", name), err.Error()))
+		if quiet[name] {
+			log.Debug("Failed script output(%s): \n %s", name, out)
+		}
+		log.HandleError(stylish.Error(fmt.Sprintf("Failed to run %s script", name), err.Error()))
 	}
 	return out, err
 }
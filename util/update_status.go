@@ -1,29 +1,156 @@
 package util
 
 import (
-	"fmt"
-	"reflect"
+	"encoding/json"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/nanopack/mist/core"
+
+	"github.com/nanobox-io/nanobox-server/config"
 )
 
-// due to the way this uses the reflect library there are certain assumptions made
-// about the interface the function recieves:
-// 1. It is of type Struct{}
-// 2. Its Kind is one of: Array, Chan, Map, Ptr, or Slice
-// 3. The ID (if any) will always be of type String
-func UpdateStatus(v interface{}, status string) {
+// JobVersion is the version of the job event, it goes up when a field changes
+// meaning or goes away
+const JobVersion = 1
+
+// JobEvent is published to mist under the "job-progress" and "<model>" tags
+// whenever a job moves on. Clients have always taken the first message under
+// the "job" and "<model>" tags as the outcome, so only the final status goes
+// there. The model, action, id and status are what clients have always read,
+// the rest of the document tells how far along the job is.
+type JobEvent struct {
+	Version  int         `json:"version"`
+	Model    string      `json:"model"`
+	Action   string      `json:"action"`
+	Document JobProgress `json:"document"`
+}
+
+// JobProgress
+type JobProgress struct {
+	ID         string    `json:"id"`
+	Status     string    `json:"status"`
+	Step       string    `json:"step,omitempty"`
+	StepIndex  int       `json:"step_index"`
+	TotalSteps int       `json:"total_steps"`
+	Percentage int       `json:"percentage"`
+	Error      *JobError `json:"error,omitempty"`
+}
+
+// JobError tells where and why a job failed
+type JobError struct {
+	Step    string `json:"step,omitempty"`
+	Message string `json:"message"`
+}
+
+// ProgressTag is the mist tag every job event is published under, the final
+// one also goes under the "job" tag
+const ProgressTag = "job-progress"
+
+// JobFinished is called with the last event of every job, once it is
+// complete, errored or unavailable
+var JobFinished func(JobEvent)
 
-	name := reflect.TypeOf(v).Elem().Name()
-	id := reflect.ValueOf(v).Elem().FieldByName("ID").String()
+// Progress follows a job through its steps and publishes every change
+type Progress struct {
+	model string
+	steps []string
 
+	mutex    sync.Mutex
+	progress JobProgress
+}
+
+// NewProgress starts following a job. model is the kind of job, eg Deploy,
+// and steps are the names of its steps in order.
+func NewProgress(model, id string, steps ...string) *Progress {
+	// jobs without an id have always been reported as 1
 	if id == "" {
 		id = "1"
 	}
+	return &Progress{
+		model:    model,
+		steps:    steps,
+		progress: JobProgress{ID: id, Status: "running", TotalSteps: len(steps)},
+	}
+}
+
+// Step moves the job on to a step
+func (p *Progress) Step(step string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.progress.Step = step
+	for i, name := range p.steps {
+		if name == step {
+			p.progress.StepIndex = i + 1
+			p.progress.Percentage = i * 100 / len(p.steps)
+		}
+	}
+	p.publish()
+}
+
+// Fail ends the job as errored in the current step
+func (p *Progress) Fail(message string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.progress.Status = "errored"
+	p.progress.Error = &JobError{Step: p.progress.Step, Message: message}
+	p.publish()
+	p.finished()
+}
+
+// Finish ends the job with a status, eg complete or unavailable
+func (p *Progress) Finish(status string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.progress.Status = status
+	if status == "complete" {
+		p.progress.StepIndex = p.progress.TotalSteps
+		p.progress.Percentage = 100
+	}
+	p.publish()
+	p.finished()
+}
+
+// Event returns the job's latest event
+func (p *Progress) Event() JobEvent {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.event()
+}
+
+// event expects the caller to hold the mutex
+func (p *Progress) event() JobEvent {
+	return JobEvent{Version: JobVersion, Model: p.model, Action: "update", Document: p.progress}
+}
+
+// finished publishes the final status where clients have always looked for it
+// and hands it to JobFinished. It expects the caller to hold the mutex.
+func (p *Progress) finished() {
+	p.publishTo("job")
+	if JobFinished != nil {
+		JobFinished(p.event())
+	}
+}
+
+// publish expects the caller to hold the mutex
+func (p *Progress) publish() {
+	p.publishTo(ProgressTag)
+}
+
+// publishTo expects the caller to hold the mutex
+func (p *Progress) publishTo(tag string) {
+	b, err := json.Marshal(p.event())
+	if err != nil {
+		return
+	}
 
 	// allow any messages that were waiting to be sent before me
 	runtime.Gosched()
-	mist.Publish([]string{"job", strings.ToLower(name)}, fmt.Sprintf(`{"model":"%s", "action":"update", "document":{"id":"%s", "status":"%s"}}`, name, id, status))
+	if err := mist.Publish([]string{tag, strings.ToLower(p.model)}, string(b)); err != nil {
+		config.Log.Error("[nanobox/util] Unable to publish the %s status: %s", p.model, err.Error())
+	}
 }
@@ -1,7 +1,15 @@
 package util_test
 
-import "testing"
-import "github.com/nanobox-io/nanobox-server/util"
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
+)
 
 func TestLock(t *testing.T) {
 	util.Lock()
@@ -13,3 +21,102 @@ func TestLock(t *testing.T) {
 		t.Errorf("the lock count should be 0 but it is %d", util.LockCount())
 	}
 }
+
+func TestAllocatePort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.PortRegistry = filepath.Join(dir, "ports.json")
+
+	port, err := util.AllocatePort("db1", "tcp", 3306, "172.17.0.2", 3306)
+	if err != nil || port != 3306 {
+		t.Errorf("db1 should have gotten 3306 but got %d (%v)", port, err)
+	}
+
+	// another service asking for the same port gets the next one
+	port, err = util.AllocatePort("db2", "tcp", 3306, "172.17.0.3", 3306)
+	if err != nil || port != 3307 {
+		t.Errorf("db2 should have gotten 3307 but got %d (%v)", port, err)
+	}
+
+	// and keeps it on the next deploy
+	port, err = util.AllocatePort("db2", "tcp", 3306, "172.17.0.3", 3306)
+	if err != nil || port != 3307 {
+		t.Errorf("db2 should have kept 3307 but got %d (%v)", port, err)
+	}
+
+	// a service without a port doesnt get one
+	if port, err = util.AllocatePort("db3", "tcp", 0, "172.17.0.4", 0); err == nil {
+		t.Errorf("db3 has no port but got %d", port)
+	}
+
+	util.ReleasePort("db1")
+	allocations := util.PortAllocations()
+	if len(allocations) != 1 || allocations[0].UID != "db2" {
+		t.Errorf("only db2 should have a port left (%+v)", allocations)
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	lines := []string{}
+	w := &util.LineWriter{Publish: func(line string) { lines = append(lines, line) }}
+
+	w.Write([]byte("building\r\nstep 1"))
+	w.Write([]byte(" done\n\nstep 2"))
+	if len(lines) != 2 || lines[0] != "building" || lines[1] != "step 1 done" {
+		t.Errorf("only the finished lines should be published (%q)", lines)
+	}
+
+	w.Flush()
+	if len(lines) != 3 || lines[2] != "step 2" {
+		t.Errorf("flush should publish the rest (%q)", lines)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	finished := []util.JobEvent{}
+	util.JobFinished = func(event util.JobEvent) { finished = append(finished, event) }
+	defer func() { util.JobFinished = nil }()
+
+	progress := util.NewProgress("Deploy", `say "hi"`, "one", "two")
+	progress.Step("one")
+	doc := progress.Event().Document
+	if doc.Status != "running" || doc.StepIndex != 1 || doc.TotalSteps != 2 || doc.Percentage != 0 {
+		t.Errorf("the first step should be 1 of 2 at 0%% (%+v)", doc)
+	}
+
+	progress.Step("two")
+	doc = progress.Event().Document
+	if doc.StepIndex != 2 || doc.Percentage != 50 {
+		t.Errorf("the second step should be 2 of 2 at 50%% (%+v)", doc)
+	}
+
+	progress.Fail("broken")
+	doc = progress.Event().Document
+	if doc.Status != "errored" || doc.Error == nil || doc.Error.Step != "two" || doc.Error.Message != "broken" {
+		t.Errorf("the job should have failed in step two (%+v)", doc)
+	}
+
+	b, err := json.Marshal(progress.Event())
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := util.JobEvent{}
+	if err := json.Unmarshal(b, &event); err != nil || event.Document.ID != `say "hi"` {
+		t.Errorf("the id should survive the json (%s)", b)
+	}
+
+	complete := util.NewProgress("Build", "abc", "one", "two")
+	complete.Step("one")
+	complete.Finish("complete")
+	doc = complete.Event().Document
+	if doc.Status != "complete" || doc.StepIndex != 2 || doc.Percentage != 100 {
+		t.Errorf("a complete job should be at 100%% (%+v)", doc)
+	}
+
+	if len(finished) != 2 || finished[0].Model != "Deploy" || finished[1].Model != "Build" {
+		t.Errorf("both jobs should have been reported as finished (%+v)", finished)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+package watch
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB
+
+// notifier reads inotify events for the watcher
+type notifier struct {
+	watcher *Watcher
+	fd      int
+	mutex   sync.Mutex
+	paths   map[int]string
+	closed  bool
+}
+
+//
+func newNotifier(w *Watcher) (*notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	return &notifier{watcher: w, fd: fd, paths: map[int]string{}}, nil
+}
+
+// add
+func (n *notifier) add(path string) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return nil
+	}
+
+	wd, err := syscall.InotifyAddWatch(n.fd, path, watchMask)
+	if err != nil {
+		return err
+	}
+	n.paths[wd] = path
+	return nil
+}
+
+// close removes every watch so the blocked read in run wakes up on the
+// IN_IGNORED events, exits and closes the fd
+func (n *notifier) close() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return
+	}
+	n.closed = true
+
+	// without any watches nothing would wake the read up, so add one just to
+	// remove it again
+	if len(n.paths) == 0 {
+		if wd, err := syscall.InotifyAddWatch(n.fd, "/", syscall.IN_ATTRIB); err == nil {
+			n.paths[wd] = "/"
+		}
+	}
+
+	for wd := range n.paths {
+		syscall.InotifyRmWatch(n.fd, uint32(wd))
+	}
+}
+
+// run
+func (n *notifier) run() {
+	defer syscall.Close(n.fd)
+
+	buf := make([]byte, (syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)*64)
+	for {
+		count, err := syscall.Read(n.fd, buf)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || count <= 0 {
+			return
+		}
+
+		offset := 0
+		for offset+syscall.SizeofInotifyEvent <= count {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			offset = nameStart + int(event.Len)
+
+			n.mutex.Lock()
+			closed := n.closed
+			dir, ok := n.paths[int(event.Wd)]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(n.paths, int(event.Wd))
+			}
+			n.mutex.Unlock()
+
+			if closed {
+				return
+			}
+			if !ok || name == "" {
+				continue
+			}
+
+			isDir := event.Mask&syscall.IN_ISDIR != 0
+			n.watcher.changed(filepath.Join(dir, name), isDir && event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// +build !linux
+
+package watch
+
+import (
+	"fmt"
+)
+
+// the server only runs inside the linux vm, everywhere else the watcher
+// is unavailable
+type notifier struct{}
+
+//
+func newNotifier(w *Watcher) (*notifier, error) {
+	return nil, fmt.Errorf("file watching is only supported on linux")
+}
+
+func (n *notifier) add(path string) error { return nil }
+func (n *notifier) close()                {}
+func (n *notifier) run()                  {}
@@ -0,0 +1,206 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package watch watches a directory tree for changes and reports them in
+// debounced batches.
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// structs
+type (
+
+	//
+	Watcher struct {
+		Root     string
+		Ignore   []string
+
+		// Debounce can be set before Start, use SetDebounce once the watcher
+		// is running
+		Debounce time.Duration
+
+		// Handler is called with every path (relative to Root) that changed
+		// since the last call once no changes have come in for Debounce
+		Handler func(paths []string)
+
+		changes  chan string
+		done     chan bool
+		mutex    sync.Mutex
+		pending  map[string]bool
+		stopped  bool
+		notifier *notifier
+	}
+)
+
+//
+func New(root string) *Watcher {
+	return &Watcher{
+		Root:     filepath.Clean(root),
+		Ignore:   []string{".git"},
+		Debounce: time.Second,
+		changes:  make(chan string, 1024),
+		done:     make(chan bool),
+		pending:  map[string]bool{},
+	}
+}
+
+// Start begins watching. It returns once every directory in the tree is being
+// watched; changes are then reported in the background until Stop is called.
+func (w *Watcher) Start() error {
+	n, err := newNotifier(w)
+	if err != nil {
+		return err
+	}
+	w.notifier = n
+
+	if err := w.watchTree(w.Root); err != nil {
+		n.close()
+		return err
+	}
+
+	go n.run()
+	go w.debounce()
+	return nil
+}
+
+// Stop
+func (w *Watcher) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.notifier == nil || w.stopped {
+		return
+	}
+	w.stopped = true
+	w.notifier.close()
+	close(w.done)
+}
+
+// SetDebounce changes how long the watcher waits for changes to stop coming
+// in, it is safe to call while the watcher is running
+func (w *Watcher) SetDebounce(d time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.Debounce = d
+}
+
+// DebounceDelay returns how long the watcher waits for changes to stop coming
+// in
+func (w *Watcher) DebounceDelay() time.Duration {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.Debounce
+}
+
+// Ignored reports whether a path relative to the root matches one of the
+// ignore patterns. A pattern matches the whole path, the file name or any of
+// the directories the path is in.
+func (w *Watcher) Ignored(rel string) bool {
+	for _, pattern := range w.Ignore {
+		pattern = strings.Trim(pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if ok, _ := filepath.Match(pattern, rel); ok {
+			return true
+		}
+		if strings.HasPrefix(rel, pattern+"/") {
+			return true
+		}
+		for _, part := range strings.Split(rel, "/") {
+			if ok, _ := filepath.Match(pattern, part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watchTree adds a watch for dir and every directory beneath it
+func (w *Watcher) watchTree(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the file went away while we were walking
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != w.Root && w.Ignored(w.relative(path)) {
+			return filepath.SkipDir
+		}
+		return w.notifier.add(path)
+	})
+}
+
+// changed is called by the notifier for every event
+func (w *Watcher) changed(path string, isDir bool) {
+	rel := w.relative(path)
+	if rel == "" || w.Ignored(rel) {
+		return
+	}
+
+	// new directories need to be watched as well
+	if isDir {
+		w.watchTree(path)
+	}
+
+	select {
+	case w.changes <- rel:
+	default:
+		// the buffer is full, we already have plenty to report
+	}
+}
+
+// debounce collects changes until they stop coming in for a while and then
+// hands them all to the handler at once
+func (w *Watcher) debounce() {
+	timer := time.NewTimer(w.DebounceDelay())
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case path := <-w.changes:
+			w.mutex.Lock()
+			w.pending[path] = true
+			delay := w.Debounce
+			w.mutex.Unlock()
+			timer.Reset(delay)
+		case <-timer.C:
+			w.mutex.Lock()
+			paths := []string{}
+			for path := range w.pending {
+				paths = append(paths, path)
+			}
+			w.pending = map[string]bool{}
+			w.mutex.Unlock()
+
+			sort.Strings(paths)
+			if len(paths) > 0 && w.Handler != nil {
+				w.Handler(paths)
+			}
+		}
+	}
+}
+
+// relative
+func (w *Watcher) relative(path string) string {
+	rel, err := filepath.Rel(w.Root, path)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,106 @@
+package watch_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/util/watch"
+)
+
+func TestIgnored(t *testing.T) {
+	w := watch.New("/code")
+	w.Ignore = []string{".git", "node_modules/", "*.swp", "tmp/cache"}
+
+	ignored := []string{
+		".git",
+		".git/HEAD",
+		"node_modules/express/index.js",
+		"app/node_modules/left-pad.js",
+		"app/.main.go.swp",
+		"tmp/cache/file",
+	}
+	for _, path := range ignored {
+		if !w.Ignored(path) {
+			t.Errorf("%s should be ignored", path)
+		}
+	}
+
+	watched := []string{
+		"main.go",
+		"app/git.go",
+		"tmp/file",
+		"swp",
+	}
+	for _, path := range watched {
+		if w.Ignored(path) {
+			t.Errorf("%s should not be ignored", path)
+		}
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	batches := make(chan []string, 10)
+	w := watch.New(dir)
+	w.Ignore = []string{"*.swp"}
+	w.Debounce = 100 * time.Millisecond
+	w.Handler = func(paths []string) {
+		batches <- paths
+	}
+	if err := w.Start(); err != nil {
+		t.Skipf("unable to watch: %s", err.Error())
+	}
+	defer w.Stop()
+
+	ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, ".a.txt.swp"), []byte("a"), 0644)
+
+	select {
+	case paths := <-batches:
+		if !reflect.DeepEqual(paths, []string{"a.txt", "sub/b.txt"}) {
+			t.Errorf("unexpected changes %+v", paths)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("no changes were reported")
+	}
+}
+
+func TestStopWithoutWatches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 5; i++ {
+		// the root isnt there so nothing is watched
+		w := watch.New(filepath.Join(dir, "missing"))
+		if err := w.Start(); err != nil {
+			t.Skipf("the watcher is unavailable: %s", err.Error())
+		}
+		w.Stop()
+	}
+
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("stopped watchers left %d goroutines running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2014 Pagoda Box Inc.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public License,
+// v. 2.0. If a copy of the MPL was not distributed with this file, You can
+// obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package webhooks lets other tools know when a job is done. Every registered
+// url gets a json POST with the job's last event:
+//
+//   {
+//     "id":   "c2b7...",                // the delivery, the same on every retry
+//     "app":  "myapp",
+//     "time": "2015-03-04T05:06:07Z",
+//     "job":  {"version": 1, "model": "Deploy", "action": "update", "document": {...}}
+//   }
+//
+// The body is signed with the webhook's secret, the X-Nanobox-Signature header
+// is "sha256=" and the hex HMAC-SHA256 of the body. Deliveries that fail or
+// get anything but a 2xx are retried with a growing delay.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
+)
+
+// Webhook is a url that is told about finished jobs. Jobs limits it to some
+// kinds of job, eg deploy and build, it gets every job when empty.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Jobs   []string `json:"jobs,omitempty"`
+}
+
+// Payload is the body of a delivery
+type Payload struct {
+	ID   string        `json:"id"`
+	App  string        `json:"app"`
+	Time time.Time     `json:"time"`
+	Job  util.JobEvent `json:"job"`
+}
+
+// MaxAttempts is how many times a delivery is tried
+var MaxAttempts = 5
+
+// RetryDelay is the wait before the first retry, it doubles every time
+var RetryDelay = 2 * time.Second
+
+// client for the deliveries
+var client = &http.Client{Timeout: 10 * time.Second}
+
+var webhooks []Webhook
+
+var webhooksTex = sync.Mutex{}
+
+// Register validates a webhook and saves it. A webhook without a secret gets
+// one, it is only shown here.
+func Register(hook Webhook) (Webhook, error) {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return hook, fmt.Errorf("a webhook needs a http or https url")
+	}
+	for i, job := range hook.Jobs {
+		hook.Jobs[i] = strings.ToLower(job)
+	}
+
+	hook.ID = uuid.New()
+	if hook.Secret == "" {
+		if hook.Secret, err = secret(); err != nil {
+			return hook, err
+		}
+	}
+
+	webhooksTex.Lock()
+	defer webhooksTex.Unlock()
+
+	hooks := append(load(), hook)
+	if err := save(hooks); err != nil {
+		return hook, err
+	}
+	webhooks = hooks
+	return hook, nil
+}
+
+// Remove
+func Remove(id string) error {
+	webhooksTex.Lock()
+	defer webhooksTex.Unlock()
+
+	hooks := []Webhook{}
+	for _, hook := range load() {
+		if hook.ID != id {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == len(load()) {
+		return fmt.Errorf("there is no webhook %q", id)
+	}
+
+	if err := save(hooks); err != nil {
+		return err
+	}
+	webhooks = hooks
+	return nil
+}
+
+// List the webhooks by url, without their secrets
+func List() []Webhook {
+	webhooksTex.Lock()
+	defer webhooksTex.Unlock()
+
+	rtn := []Webhook{}
+	for _, hook := range load() {
+		hook.Secret = ""
+		rtn = append(rtn, hook)
+	}
+	sort.Sort(byURL(rtn))
+	return rtn
+}
+
+// Notify delivers a finished job to every webhook that wants it. It returns
+// right away, the deliveries happen in the background.
+func Notify(event util.JobEvent) {
+	webhooksTex.Lock()
+	hooks := load()
+	webhooksTex.Unlock()
+
+	payload := Payload{ID: uuid.New(), App: config.App(), Time: time.Now(), Job: event}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	model := strings.ToLower(event.Model)
+	for _, hook := range hooks {
+		if len(hook.Jobs) > 0 && !contains(hook.Jobs, model) {
+			continue
+		}
+		go deliver(hook, payload.ID, model, body)
+	}
+}
+
+// Sign returns the signature of a body for a secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts the body until the webhook takes it or MaxAttempts is reached
+func deliver(hook Webhook, id, model string, body []byte) {
+	delay := RetryDelay
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		err := post(hook, id, model, body)
+		if err == nil {
+			return
+		}
+
+		config.Log.Warn("[nanobox/webhooks] Delivery %s to %s failed (attempt %d of %d): %s", id, hook.URL, attempt, MaxAttempts, err.Error())
+		if attempt < MaxAttempts {
+			<-time.After(delay)
+			delay *= 2
+		}
+	}
+}
+
+// post
+func post(hook Webhook, id, model string, body []byte) error {
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nanobox-Event", model)
+	req.Header.Set("X-Nanobox-Delivery", id)
+	req.Header.Set("X-Nanobox-Signature", Sign(hook.Secret, body))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("responded with %s", res.Status)
+	}
+	return nil
+}
+
+// secret makes a random secret
+func secret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// load reads the webhooks the first time they are needed. It expects the
+// caller to hold the webhooksTex lock.
+func load() []Webhook {
+	if webhooks != nil {
+		return webhooks
+	}
+
+	webhooks = []Webhook{}
+	b, err := ioutil.ReadFile(config.WebhookRegistry)
+	if err != nil {
+		return webhooks
+	}
+	if err := json.Unmarshal(b, &webhooks); err != nil {
+		config.Log.Error("[nanobox/webhooks] Unable to read the webhooks: %s", err.Error())
+	}
+	return webhooks
+}
+
+// save
+func save(hooks []Webhook) error {
+	b, err := json.Marshal(hooks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(config.WebhookRegistry), 0755); err != nil {
+		return err
+	}
+	// the secrets are in here
+	return ioutil.WriteFile(config.WebhookRegistry, b, 0600)
+}
+
+// contains
+func contains(list []string, item string) bool {
+	for _, entry := range list {
+		if entry == item {
+			return true
+		}
+	}
+	return false
+}
+
+// byURL
+type byURL []Webhook
+
+func (w byURL) Len() int           { return len(w) }
+func (w byURL) Swap(i, j int)      { w[i], w[j] = w[j], w[i] }
+func (w byURL) Less(i, j int) bool { return w[i].URL < w[j].URL }
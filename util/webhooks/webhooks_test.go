@@ -0,0 +1,86 @@
+package webhooks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nanobox-io/nanobox-server/config"
+	"github.com/nanobox-io/nanobox-server/util"
+	"github.com/nanobox-io/nanobox-server/util/webhooks"
+)
+
+func TestNotify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.WebhookRegistry = filepath.Join(dir, "webhooks.json")
+	webhooks.RetryDelay = 10 * time.Millisecond
+
+	// the first delivery fails so it has to be retried
+	attempts := int32(0)
+	delivered := make(chan webhooks.Payload, 1)
+	var hook webhooks.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			rw.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		if req.Header.Get("X-Nanobox-Signature") != webhooks.Sign(hook.Secret, body) {
+			t.Errorf("the delivery is not signed with the secret")
+		}
+		payload := webhooks.Payload{}
+		json.Unmarshal(body, &payload)
+		delivered <- payload
+	}))
+	defer server.Close()
+
+	if _, err := webhooks.Register(webhooks.Webhook{URL: "localhost:1234"}); err == nil {
+		t.Errorf("a url without a scheme should not be valid")
+	}
+	if _, err := webhooks.Register(webhooks.Webhook{URL: server.URL, Jobs: []string{"Deploy"}}); err != nil {
+		t.Fatal(err)
+	}
+	hook = webhooks.List()[0]
+	if hook.Secret != "" {
+		t.Errorf("the secret should not be listed")
+	}
+
+	// the registry keeps the secret
+	saved := []webhooks.Webhook{}
+	b, _ := ioutil.ReadFile(config.WebhookRegistry)
+	json.Unmarshal(b, &saved)
+	if len(saved) != 1 || saved[0].Secret == "" {
+		t.Fatalf("the webhook should be saved with its secret (%s)", b)
+	}
+	hook.Secret = saved[0].Secret
+
+	// builds are not wanted
+	webhooks.Notify(util.JobEvent{Model: "Build", Document: util.JobProgress{ID: "b1", Status: "complete"}})
+	webhooks.Notify(util.JobEvent{Model: "Deploy", Document: util.JobProgress{ID: `"quoted"`, Status: "errored"}})
+
+	select {
+	case payload := <-delivered:
+		if payload.Job.Model != "Deploy" || payload.Job.Document.ID != `"quoted"` {
+			t.Errorf("the wrong job was delivered (%+v)", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the delivery was not retried")
+	}
+
+	if err := webhooks.Remove(hook.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(webhooks.List()) != 0 {
+		t.Errorf("the webhook should be gone")
+	}
+}